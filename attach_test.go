@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"github.com/vaughan0/go-ini"
+	"strings"
+	"testing"
+)
+
+func TestOutputThresholds(t *testing.T) {
+	var msgs msgSlice
+	h := NewHierarchy()
+	h.RegisterOutputPlugin("mock", &msgs)
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = DEBUG, mock@WARN
+  a = DEBUG, mock@INFO, nopropagate
+
+  [mock]
+  type = mock
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Get("b").Info("dropped")
+	h.Get("b").Error("root")
+	h.Get("a").Debug("dropped")
+	h.Get("a").Info("a")
+	if len(msgs) != 2 || msgs[0].Msg != "root" || msgs[1].Msg != "a" {
+		t.Errorf("unexpected messages: %v", msgs)
+	}
+
+	dump := new(strings.Builder)
+	if err := h.DumpConfig(dump); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dump.String(), "mock@WARN") || !strings.Contains(dump.String(), "mock@INFO") {
+		t.Errorf("output thresholds missing from dump:\n%s", dump)
+	}
+
+	file, err := ini.Load(strings.NewReader(`
+  [loggers]
+  root = DEBUG, mock@LOUD
+
+  [mock]
+  type = mock
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.ValidateConfig(IniConfig(file))
+	if err == nil || !strings.Contains(err.Error(), "invalid output threshold: mock@LOUD") {
+		t.Errorf("unexpected validation error: %v", err)
+	}
+}
+
+func TestAddOutputThreshold(t *testing.T) {
+	var msgs msgSlice
+	logger := NewHierarchy().Get("x")
+	logger.SetThreshold(Debug)
+	logger.AddOutputThreshold(&msgs, Warn)
+	logger.Info("dropped")
+	logger.Warn("kept")
+	if len(msgs) != 1 || msgs[0].Msg != "kept" {
+		t.Errorf("unexpected messages: %v", msgs)
+	}
+}
+
+func TestAttachAndRemove(t *testing.T) {
+	h := NewHierarchy()
+	var a, b msgSlice
+	logger := h.Get("attach")
+	logger.SetThreshold(Info)
+	tee := TeeOutputter{&a, &b}
+	detach := logger.Attach(tee)
+	logger.AddOutput(&a)
+
+	// Uncomparable outputters are never found, rather than causing a panic
+	logger.RemoveOutput(TeeOutputter{&a, &b})
+	logger.Info("one")
+	if len(a) != 2 || len(b) != 1 {
+		t.Fatalf("unexpected messages: %v %v", a, b)
+	}
+
+	detach()
+	detach()
+	logger.Info("two")
+	if len(a) != 3 || len(b) != 1 {
+		t.Errorf("tee was not detached: %v %v", a, b)
+	}
+	logger.RemoveOutput(&a)
+	if outputs := logger.Outputs(); len(outputs) != 0 {
+		t.Errorf("unexpected outputs: %v", outputs)
+	}
+}
@@ -0,0 +1,473 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Returned by BatchOutputter when a message is discarded because its queue is full.
+var ErrQueueFull = errors.New("output queue is full")
+
+// Returned by BatchOutputter when a message is output after the BatchOutputter has been closed.
+var ErrOutputClosed = errors.New("output is closed")
+
+// A BatchSender sends a batch of messages to some destination, typically in a single network request. It is used by
+// BatchOutputter, which never calls SendBatch concurrently.
+type BatchSender interface {
+	SendBatch(msgs []*Message) error
+}
+
+type BatchSenderFunc func(msgs []*Message) error
+
+// Implements BatchSender.
+func (f BatchSenderFunc) SendBatch(msgs []*Message) error {
+	return f(msgs)
+}
+
+// PermanentError can be returned by a BatchSender to indicate that sending the batch again would fail too, so it is not
+// retried.
+type PermanentError struct {
+	Err error
+}
+
+func (p PermanentError) Error() string {
+	return p.Err.Error()
+}
+
+func (p PermanentError) Unwrap() error {
+	return p.Err
+}
+
+// RetryAfterError can be returned by a BatchSender when the destination has asked for the batch to be sent again after a
+// delay, such as with the Retry-After header of an HTTP response. The delay is used if it is longer than the backoff, but
+// never for longer than the MaxRetryDelay of the BatchOptions.
+type RetryAfterError struct {
+	Err   error
+	After time.Duration
+}
+
+func (r RetryAfterError) Error() string {
+	return r.Err.Error()
+}
+
+func (r RetryAfterError) Unwrap() error {
+	return r.Err
+}
+
+// BatchOptions controls how a BatchOutputter groups messages, and how often it retries.
+type BatchOptions struct {
+	// The maximum number of messages in a batch. Defaults to 100.
+	Size int
+	// The longest time that a message waits before its batch is sent. Defaults to one second.
+	Interval time.Duration
+	// The maximum number of messages waiting to be batched. Defaults to 1000.
+	QueueSize int
+	// The number of times a failed batch is sent again. Defaults to 3, and a negative value disables retries.
+	MaxRetries int
+	// The time to wait before the first retry, which doubles after each attempt. Defaults to 100 milliseconds.
+	RetryBackoff time.Duration
+	// The longest time to wait before a retry, which limits both the backoff and the delays that the destination asks
+	// for (see RetryAfterError). Defaults to one minute.
+	MaxRetryDelay time.Duration
+	// Called with a batch that could not be sent after all retries failed. It can be used to save the messages
+	// elsewhere.
+	OnDrop func(msgs []*Message, err error)
+}
+
+// The options read by ParseBatchOptions, which plugins that use BatchOutputter can pass to RegisterPluginOptions.
+var BatchPluginOptions = []string{"batch_size", "batch_interval", "queue_size", "max_retries", "retry_backoff",
+	"max_retry_delay"}
+
+// Reads BatchOptions from an output section's options. The "batch_size", "queue_size" and "max_retries" options are
+// integers, and "batch_interval", "retry_backoff" and "max_retry_delay" are durations as accepted by
+// time.ParseDuration. Missing options are left as zero, so that the defaults are used.
+func ParseBatchOptions(options map[string]string) (b BatchOptions, err error) {
+	for key, dest := range map[string]*int{"batch_size": &b.Size, "queue_size": &b.QueueSize, "max_retries": &b.MaxRetries} {
+		if value, ok := options[key]; ok {
+			if *dest, err = strconv.Atoi(value); err != nil {
+				return b, errors.New("invalid " + key + ": " + value)
+			}
+		}
+	}
+	for key, dest := range map[string]*time.Duration{"batch_interval": &b.Interval, "retry_backoff": &b.RetryBackoff,
+		"max_retry_delay": &b.MaxRetryDelay} {
+		if value, ok := options[key]; ok {
+			if *dest, err = time.ParseDuration(value); err != nil {
+				return b, errors.New("invalid " + key + ": " + value)
+			}
+		}
+	}
+	return b, nil
+}
+
+// BatchOutputter implements Outputter by queueing messages and sending them in batches from a background goroutine.
+// Messages are never blocked by the destination: if the queue is full, messages are discarded and ErrQueueFull is
+// returned. Batches that fail to send are retried, and errors are reported in the same way as other output errors (see
+// OnError).
+type BatchOutputter struct {
+	sender  BatchSender
+	options BatchOptions
+	queue   chan *Message
+	flushes chan chan struct{}
+	closing chan struct{}
+	closed  chan struct{}
+	once    sync.Once
+	dropped uint64
+	// The name given by NewNamedOutputter, as a string.
+	name atomic.Value
+	// The error returned by the sender's Close method.
+	closeErr error
+}
+
+// Creates a BatchOutputter and starts its goroutine, which runs until Close is called.
+func NewBatchOutputter(sender BatchSender, options BatchOptions) *BatchOutputter {
+	if options.Size <= 0 {
+		options.Size = 100
+	}
+	if options.Interval <= 0 {
+		options.Interval = time.Second
+	}
+	if options.QueueSize <= 0 {
+		options.QueueSize = 1000
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = 3
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = 100 * time.Millisecond
+	}
+	if options.MaxRetryDelay <= 0 {
+		options.MaxRetryDelay = time.Minute
+	}
+	b := &BatchOutputter{
+		sender:  sender,
+		options: options,
+		queue:   make(chan *Message, options.QueueSize),
+		flushes: make(chan chan struct{}),
+		closing: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	go b.run()
+	return b
+}
+
+// Implements Outputter.
+func (b *BatchOutputter) Output(msg *Message) {
+	b.OutputErr(msg)
+}
+
+// Implements ErrorOutputter. Only errors that occur while queueing the message are returned.
+func (b *BatchOutputter) OutputErr(msg *Message) error {
+	select {
+	case <-b.closing:
+		return ErrOutputClosed
+	default:
+	}
+	select {
+	case b.queue <- msg:
+		return nil
+	default:
+		b.drop(1)
+		return ErrQueueFull
+	}
+}
+
+// Implements StackWanter. A BatchOutputter needs stack traces if its BatchSender has a WantsStack method that reports
+// that it does.
+func (b *BatchOutputter) WantsStack() bool {
+	wanter, ok := b.sender.(interface{ WantsStack() bool })
+	return ok && wanter.WantsStack()
+}
+
+func (b *BatchOutputter) setName(name string) {
+	b.name.Store(name)
+}
+
+// Returns the Outputter that errors are reported for, which carries the BatchOutputter's name if it has one.
+func (b *BatchOutputter) named() Outputter {
+	if name, _ := b.name.Load().(string); name != "" {
+		return NamedOutputter{Name: name, Outputter: b}
+	}
+	return b
+}
+
+// Counts discarded messages.
+func (b *BatchOutputter) drop(count int) {
+	atomic.AddUint64(&b.dropped, uint64(count))
+	recordDropped(outputName(b.named()), count)
+}
+
+// Returns the number of messages waiting to be sent.
+func (b *BatchOutputter) QueueLen() int {
+	return len(b.queue)
+}
+
+// Returns the number of messages that were discarded, either because the queue was full or because their batch could
+// not be sent.
+func (b *BatchOutputter) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Sends every queued message, and waits until they have been sent or dropped.
+func (b *BatchOutputter) Flush() error {
+	return b.FlushContext(context.Background())
+}
+
+// Like Flush, but stops waiting when the context is done, and returns the context's error. The messages are still sent
+// in the background.
+func (b *BatchOutputter) FlushContext(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case b.flushes <- done:
+	case <-b.closed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sends every queued message and stops the BatchOutputter's goroutine. Messages that are output afterwards are
+// discarded. If the BatchSender implements io.Closer, it is closed too, and its error is returned.
+func (b *BatchOutputter) Close() error {
+	b.once.Do(func() {
+		close(b.closing)
+	})
+	<-b.closed
+	return b.closeErr
+}
+
+func (b *BatchOutputter) run() {
+	defer close(b.closed)
+	ticker := time.NewTicker(b.options.Interval)
+	defer ticker.Stop()
+	var batch []*Message
+	for {
+		select {
+		case msg := <-b.queue:
+			batch = append(batch, msg)
+			if len(batch) >= b.options.Size {
+				b.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.send(batch)
+				batch = nil
+			}
+		case done := <-b.flushes:
+			b.sendAll(batch)
+			batch = nil
+			close(done)
+		case <-b.closing:
+			b.sendAll(batch)
+			if closer, ok := b.sender.(io.Closer); ok {
+				b.closeErr = closer.Close()
+			}
+			return
+		}
+	}
+}
+
+// Sends a partial batch along with every queued message.
+func (b *BatchOutputter) sendAll(batch []*Message) {
+	for {
+		select {
+		case msg := <-b.queue:
+			batch = append(batch, msg)
+			if len(batch) < b.options.Size {
+				continue
+			}
+		default:
+		}
+		if len(batch) == 0 {
+			return
+		}
+		full := len(batch) >= b.options.Size
+		b.send(batch)
+		batch = nil
+		if !full {
+			return
+		}
+	}
+}
+
+func (b *BatchOutputter) send(batch []*Message) {
+	backoff := b.options.RetryBackoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = b.sender.SendBatch(batch); err == nil {
+			return
+		}
+		var permanent PermanentError
+		if errors.As(err, &permanent) || attempt >= b.options.MaxRetries {
+			break
+		}
+		wait := backoff
+		var retryAfter RetryAfterError
+		if errors.As(err, &retryAfter) && retryAfter.After > wait {
+			wait = retryAfter.After
+		}
+		if wait > b.options.MaxRetryDelay {
+			wait = b.options.MaxRetryDelay
+		}
+		// Retries are not delayed once the BatchOutputter is closing, so that Close does not wait for them
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-b.closing:
+			timer.Stop()
+		}
+		backoff *= 2
+	}
+	b.drop(len(batch))
+	reportErrors(b.named(), batch, err)
+	if b.options.OnDrop != nil {
+		b.options.OnDrop(batch, err)
+	}
+}
+
+// Returns the Outputter that is wrapped by the standard wrappers, such as the ones created for the "threshold" and
+// "stacktrace" options.
+func baseOutputter(o Outputter) Outputter {
+	for {
+		switch wrapper := o.(type) {
+		case NamedOutputter:
+			o = wrapper.Outputter
+		case ThresholdOutputter:
+			o = wrapper.Outputter
+		case StackOutputter:
+			o = wrapper.Outputter
+		default:
+			return o
+		}
+	}
+}
+
+// An output that buffers messages.
+type flusher interface {
+	Flush() error
+}
+
+// Flushes every output that buffers messages, such as a BatchOutputter: both the outputs of the current configuration
+// and the outputs that were attached to loggers with AddOutput or Attach. Outputs that buffer messages have a method
+// with the signature Flush() error. The first error that occurs is returned.
+func (h *Hierarchy) Flush() error {
+	return h.FlushContext(context.Background())
+}
+
+// Like Flush, but stops waiting for the outputs when the context is done, and returns the context's error. Outputs
+// that have a method with the signature FlushContext(ctx context.Context) error are passed the context, while other
+// outputs are left to finish flushing in the background.
+func (h *Hierarchy) FlushContext(ctx context.Context) (err error) {
+	for _, output := range h.flushers() {
+		if ferr := flushContext(ctx, output); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return
+}
+
+// Returns the outputs of the current configuration and of every logger that buffer messages, without repeating
+// outputs that are referred to by pointer.
+func (h *Hierarchy) flushers() (result []flusher) {
+	seen := make(map[flusher]bool)
+	add := func(output Outputter) {
+		f, ok := baseOutputter(output).(flusher)
+		if !ok {
+			return
+		}
+		if reflect.TypeOf(f).Kind() == reflect.Ptr {
+			if seen[f] {
+				return
+			}
+			seen[f] = true
+		}
+		result = append(result, f)
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	names := make([]string, 0, len(h.outputters))
+	for name := range h.outputters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		add(h.outputters[name])
+	}
+	h.Root.walk(func(logger *Logger) {
+		for _, attached := range logger.attachments() {
+			add(attached.output)
+		}
+	})
+	return
+}
+
+// Flushes an output, and stops waiting for it when the context is done.
+func flushContext(ctx context.Context, f flusher) error {
+	if ctxFlusher, ok := f.(interface {
+		FlushContext(ctx context.Context) error
+	}); ok {
+		return ctxFlusher.FlushContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- f.Flush()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Flushes the outputs of the Default hierarchy (see Hierarchy.Flush).
+func Flush() error {
+	return Default.Flush()
+}
+
+// Flushes the outputs of the Default hierarchy, until the context is done (see Hierarchy.FlushContext).
+func FlushContext(ctx context.Context) error {
+	return Default.FlushContext(ctx)
+}
+
+// Returns the outputs created from configuration sections that must be closed when they are replaced.
+func closers(outputters map[string]Outputter, predefined map[string]Outputter) (result []io.Closer) {
+	for name, output := range outputters {
+		if predefined[name] != nil {
+			continue
+		}
+		if closer, ok := baseOutputter(output).(io.Closer); ok {
+			result = append(result, closer)
+		}
+	}
+	return
+}
+
+// Closes the outputs of a configuration that has been replaced, or that could not be applied, and returns the errors
+// that occurred.
+func closeAll(closers []io.Closer) error {
+	var errs []error
+	for _, closer := range closers {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,298 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type batchRecorder struct {
+	lock    sync.Mutex
+	batches [][]string
+	fail    int
+	err     error
+	closed  bool
+}
+
+func (b *batchRecorder) SendBatch(msgs []*Message) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.fail > 0 {
+		b.fail--
+		return b.err
+	}
+	var batch []string
+	for _, msg := range msgs {
+		batch = append(batch, msg.Msg)
+	}
+	b.batches = append(b.batches, batch)
+	return nil
+}
+
+func (b *batchRecorder) Close() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *batchRecorder) failures() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.fail
+}
+
+func (b *batchRecorder) count() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.batches)
+}
+
+func TestBatchOutputter(t *testing.T) {
+	sender := &batchRecorder{fail: 2, err: errors.New("unavailable")}
+	out := NewBatchOutputter(sender, BatchOptions{Size: 2, Interval: time.Hour, RetryBackoff: time.Millisecond})
+	for _, text := range []string{"a", "b", "c"} {
+		out.Output(&Message{Msg: text})
+	}
+	// The first batch is sent when it is full, after two retries
+	deadline := time.Now().Add(5 * time.Second)
+	for sender.count() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("full batch was not sent")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	out.Flush()
+	if sender.count() != 2 || sender.batches[0][1] != "b" || sender.batches[1][0] != "c" {
+		t.Errorf("unexpected batches: %v", sender.batches)
+	}
+
+	out.Close()
+	if err := out.OutputErr(&Message{Msg: "late"}); err != ErrOutputClosed {
+		t.Errorf("unexpected error after close: %v", err)
+	}
+	if !sender.closed {
+		t.Error("sender was not closed")
+	}
+}
+
+func TestBatchOutputterDrops(t *testing.T) {
+	var errs []string
+	OnError(func(output string, err error) {
+		errs = append(errs, err.Error())
+	})
+	defer OnError(nil)
+
+	var dropped []*Message
+	sender := &batchRecorder{fail: 10, err: PermanentError{errors.New("bad request")}}
+	out := NewBatchOutputter(sender, BatchOptions{
+		Interval: time.Hour,
+		OnDrop: func(msgs []*Message, err error) {
+			dropped = msgs
+		},
+	})
+	out.Output(&Message{Msg: "a", Logger: Root})
+	out.Close()
+	if sender.fail != 9 || len(dropped) != 1 || out.Dropped() != 1 {
+		t.Errorf("permanent error was retried or not dropped: %d, %v", sender.fail, dropped)
+	}
+	if len(errs) != 1 || errs[0] != "bad request" {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	blocked := NewBatchOutputter(BatchSenderFunc(func(msgs []*Message) error {
+		<-release
+		return nil
+	}), BatchOptions{Size: 1, QueueSize: 1})
+	var full bool
+	for i := 0; i < 10 && !full; i++ {
+		full = blocked.OutputErr(&Message{Msg: "x"}) == ErrQueueFull
+	}
+	if !full {
+		t.Error("queue did not fill up")
+	}
+}
+
+// Records the messages dropped by outputs with a given name.
+type dropSink struct {
+	output  string
+	lock    sync.Mutex
+	dropped int
+}
+
+func (d *dropSink) MessageLogged(logger string, level Level)            {}
+func (d *dropSink) OutputError(output string)                           {}
+func (d *dropSink) OutputLatency(output string, duration time.Duration) {}
+
+func (d *dropSink) OutputDropped(output string, count int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if output == d.output {
+		d.dropped += count
+	}
+}
+
+func TestNamedBatchOutputter(t *testing.T) {
+	var errs []string
+	OnError(func(output string, err error) {
+		errs = append(errs, output+": "+err.Error())
+	})
+	defer OnError(nil)
+	sink := &dropSink{output: "batched"}
+	AddMetricsSink(sink)
+
+	h := NewHierarchy()
+	out := NewBatchOutputter(&batchRecorder{fail: 10, err: PermanentError{errors.New("bad request")}}, BatchOptions{Interval: time.Hour})
+	defer out.Close()
+	h.RegisterOutputPlugin("batch", OutputPluginFunc(func(options map[string]string) (Outputter, error) {
+		return out, nil
+	}))
+	if err := h.SetupReader(strings.NewReader("[loggers]\nroot = INFO, batched\n[batched]\ntype = batch\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every message of the failed batch is written to the emergency fallback
+	SetEmergencyFallback(true)
+	defer SetEmergencyFallback(false)
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	h.Get("app").Info("one")
+	h.Get("app").Info("two")
+	h.Flush()
+	os.Stderr = stderr
+	w.Close()
+	fallback, _ := io.ReadAll(r)
+
+	if len(errs) != 1 || errs[0] != "batched: bad request" {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if strings.Count(string(fallback), "(output batched failed: bad request)") != 2 {
+		t.Errorf("unexpected emergency fallback output: %s", fallback)
+	}
+	stats := h.Stats()
+	if stats.Dropped["batched"] != 2 || stats.Queues["batched"] != 0 {
+		t.Errorf("unexpected queue stats: %v, %v", stats.Dropped, stats.Queues)
+	}
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	if sink.dropped != 2 {
+		t.Errorf("expected 2 dropped messages to be reported, got %d", sink.dropped)
+	}
+}
+
+func TestBatchOutputterClosedOnError(t *testing.T) {
+	h := NewHierarchy()
+	var created []*BatchOutputter
+	h.RegisterOutputPlugin("batch", OutputPluginFunc(func(options map[string]string) (Outputter, error) {
+		out := NewBatchOutputter(&batchRecorder{}, BatchOptions{})
+		created = append(created, out)
+		return out, nil
+	}))
+	h.RegisterOutputPlugin("broken", OutputPluginFunc(func(options map[string]string) (Outputter, error) {
+		return nil, errors.New("broken")
+	}))
+	config := &StructuredConfig{
+		Loggers: map[string]string{"root": "INFO, first, second, broken"},
+		Outputs: []PluginConfig{
+			{"first", map[string]string{"type": "batch"}},
+			{"second", map[string]string{"type": "batch"}},
+			{"broken", map[string]string{"type": "broken"}},
+		},
+	}
+	if err := h.SetupConfig(config); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(created) != 2 {
+		t.Fatalf("expected 2 outputs to be created, got %d", len(created))
+	}
+	for _, out := range created {
+		if err := out.OutputErr(&Message{}); err != ErrOutputClosed {
+			t.Errorf("output was not closed: %v", err)
+		}
+	}
+}
+
+func TestBatchRetryDelays(t *testing.T) {
+	options, err := ParseBatchOptions(map[string]string{"max_retry_delay": "10ms"})
+	if err != nil || options.MaxRetryDelay != 10*time.Millisecond {
+		t.Fatalf("unexpected options: %v, %v", options, err)
+	}
+
+	// Delays requested by the destination are limited
+	sender := &batchRecorder{fail: 1, err: RetryAfterError{errors.New("busy"), time.Hour}}
+	out := NewBatchOutputter(sender, options)
+	out.Output(&Message{Msg: "a"})
+	done := make(chan struct{})
+	go func() {
+		out.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("retry was not limited by the maximum delay")
+	}
+	if sender.count() != 1 {
+		t.Errorf("batch was not sent again: %v", sender.batches)
+	}
+	out.Close()
+
+	// Closing does not wait for a retry
+	sender = &batchRecorder{fail: 1, err: errors.New("unavailable")}
+	out = NewBatchOutputter(sender, BatchOptions{Size: 1, RetryBackoff: time.Hour, MaxRetryDelay: time.Hour})
+	out.Output(&Message{Msg: "b"})
+	for start := time.Now(); sender.failures() != 0 && time.Since(start) < 5*time.Second; {
+		time.Sleep(time.Millisecond)
+	}
+	closed := make(chan struct{})
+	go func() {
+		out.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close waited for a retry")
+	}
+	if sender.count() != 1 {
+		t.Errorf("batch was not sent again while closing: %v", sender.batches)
+	}
+}
+
+func TestFlushContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	blocked := NewBatchOutputter(BatchSenderFunc(func(msgs []*Message) error {
+		<-release
+		return nil
+	}), BatchOptions{})
+	queued := NewBatchOutputter(&batchRecorder{}, BatchOptions{Interval: time.Hour})
+	defer queued.Close()
+
+	// Outputs that were attached directly are flushed as well as configured ones
+	h := NewHierarchy()
+	h.Root.SetThreshold(Info)
+	h.Root.AddOutput(queued)
+	h.Get("app").AddOutput(blocked)
+	h.Get("app").Info("message")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := h.FlushContext(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected the flush to time out, got %v", err)
+	}
+	if queued.QueueLen() != 0 {
+		t.Error("attached output was not flushed")
+	}
+}
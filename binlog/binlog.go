@@ -0,0 +1,508 @@
+// Package binlog provides a compact binary encoding of go-logging messages, for services that log too much to format
+// every message as text. Encoded logs can be read back with a Decoder, or with the go-logging-cat command.
+//
+// Importing the package registers the "binary" output type, which appends encoded messages to a file:
+//
+//	[binlog]
+//	type = binary
+//	file = /var/log/myapp.binlog
+//	buffer_size = 65536
+//	flush_interval = 1s
+//
+// A log starts with the bytes "GLB1", and is followed by records. Each record is a uvarint length and then that many
+// bytes, the first of which is the record type:
+//
+//   - A string record (1) defines the next entry of the string table, which starts empty. Logger names and file names
+//     are written once, and referred to by their index in the table afterwards.
+//   - A reset record (2) empties the string table. Encoders write one when they start, so that logs can be appended to.
+//   - A message record (3) holds the level and time as varints, the logger and file as string table indices, the line
+//     and flags as uvarints, and then the message, stack trace and errors as length-prefixed strings.
+//
+// A record that was only partly written, such as when the program crashed, is cut off when the log is opened again, so
+// that the records appended afterwards can be read.
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The bytes that every log starts with.
+const Magic = "GLB1"
+
+const (
+	recordString  = 1
+	recordReset   = 2
+	recordMessage = 3
+)
+
+// The flag set in message records that were logged with tracing enabled.
+const flagTraced = 1
+
+// The maximum number of entries in the string table. When it is full, the encoder empties it with a reset record.
+const maxStrings = 4096
+
+// The maximum length of a record accepted by a Decoder, which protects it from corrupt input.
+const maxRecord = 64 << 20
+
+// Returned by a Decoder when the input does not start with Magic.
+var ErrNotBinlog = errors.New("not a binary log")
+
+// An Encoder writes encoded messages to an io.Writer. It is not safe for concurrent use.
+type Encoder struct {
+	w       io.Writer
+	strings map[string]uint64
+	buf     []byte
+	record  []byte
+	started bool
+}
+
+// Creates an Encoder. The header (see WriteHeader) is not written, so that messages can be appended to an existing log.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, strings: make(map[string]uint64)}
+}
+
+// Writes the Magic bytes that start a log.
+func WriteHeader(w io.Writer) error {
+	_, err := io.WriteString(w, Magic)
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// Appends a record to the buffer.
+func (e *Encoder) appendRecord(record []byte) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(record)))
+	e.buf = append(e.buf, record...)
+}
+
+// Returns the index of a string in the table, adding it with a string record if it is new.
+func (e *Encoder) intern(s string) uint64 {
+	if index, ok := e.strings[s]; ok {
+		return index
+	}
+	index := uint64(len(e.strings))
+	e.strings[s] = index
+	e.appendRecord(append([]byte{recordString}, s...))
+	return index
+}
+
+// Encodes a message and writes it, along with any string records that it needs, in a single write.
+func (e *Encoder) Encode(msg *logging.Message) error {
+	e.buf = e.buf[:0]
+	if !e.started {
+		e.appendRecord([]byte{recordReset})
+		e.started = true
+	}
+	name := ""
+	if msg.Logger != nil {
+		name = msg.Logger.Name
+	}
+	// The table is emptied before interning either string if they would not both fit, since emptying it in between
+	// would discard the first one
+	added := 0
+	if _, ok := e.strings[name]; !ok {
+		added++
+	}
+	if _, ok := e.strings[msg.File]; !ok && msg.File != name {
+		added++
+	}
+	if len(e.strings)+added > maxStrings {
+		e.strings = make(map[string]uint64)
+		e.appendRecord([]byte{recordReset})
+	}
+	logger, file := e.intern(name), e.intern(msg.File)
+
+	record := append(e.record[:0], recordMessage)
+	record = binary.AppendVarint(record, int64(msg.Level))
+	record = binary.AppendVarint(record, msg.Time.UnixNano())
+	record = binary.AppendUvarint(record, logger)
+	record = binary.AppendUvarint(record, file)
+	record = binary.AppendUvarint(record, uint64(msg.Line))
+	var flags uint64
+	if msg.Traced {
+		flags |= flagTraced
+	}
+	record = binary.AppendUvarint(record, flags)
+	record = appendString(record, msg.Msg)
+	record = appendString(record, msg.Stack)
+	record = binary.AppendUvarint(record, uint64(len(msg.Errors)))
+	for _, err := range msg.Errors {
+		record = appendString(record, err.Error())
+	}
+	e.appendRecord(record)
+	e.record = record
+
+	// After a failed write, the reader may have missed string records, so the table is started again
+	if _, err := e.w.Write(e.buf); err != nil {
+		e.started = false
+		e.strings = make(map[string]uint64)
+		return err
+	}
+	return nil
+}
+
+// A Decoder reads messages from an encoded log. Decoded messages refer to loggers of the Decoder's Hierarchy, which are
+// created as needed.
+type Decoder struct {
+	// The hierarchy that loggers are taken from. Defaults to a new Hierarchy.
+	Hierarchy *logging.Hierarchy
+
+	r       *bufio.Reader
+	strings []string
+	started bool
+}
+
+// Creates a Decoder.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{Hierarchy: logging.NewHierarchy(), r: bufio.NewReader(r)}
+}
+
+type decodeError string
+
+func (d decodeError) Error() string {
+	return "corrupt binary log: " + string(d)
+}
+
+// Reads the next message. io.EOF is returned at the end of the log, and io.ErrUnexpectedEOF if the log ends in the
+// middle of a record.
+func (d *Decoder) Decode() (*logging.Message, error) {
+	if !d.started {
+		magic := make([]byte, len(Magic))
+		if _, err := io.ReadFull(d.r, magic); err != nil || string(magic) != Magic {
+			return nil, ErrNotBinlog
+		}
+		d.started = true
+	}
+	for {
+		length, err := binary.ReadUvarint(d.r)
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 || length > maxRecord {
+			return nil, decodeError("invalid record length " + strconv.FormatUint(length, 10))
+		}
+		record := make([]byte, length)
+		if _, err := io.ReadFull(d.r, record); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		switch record[0] {
+		case recordString:
+			d.strings = append(d.strings, string(record[1:]))
+		case recordReset:
+			d.strings = d.strings[:0]
+		case recordMessage:
+			return d.message(record[1:])
+		default:
+			return nil, decodeError("unknown record type " + strconv.Itoa(int(record[0])))
+		}
+	}
+}
+
+// Reads the fields of a message record.
+type recordReader struct {
+	data []byte
+	err  error
+}
+
+func (r *recordReader) varint() int64 {
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *recordReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.fail()
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *recordReader) string() string {
+	length := r.uvarint()
+	if length > uint64(len(r.data)) {
+		r.fail()
+		return ""
+	}
+	s := string(r.data[:length])
+	r.data = r.data[length:]
+	return s
+}
+
+func (r *recordReader) fail() {
+	if r.err == nil {
+		r.err = decodeError("truncated message record")
+	}
+	r.data = nil
+}
+
+func (d *Decoder) lookup(index uint64) (string, error) {
+	if index >= uint64(len(d.strings)) {
+		return "", decodeError("undefined string " + strconv.FormatUint(index, 10))
+	}
+	return d.strings[index], nil
+}
+
+func (d *Decoder) message(data []byte) (*logging.Message, error) {
+	r := &recordReader{data: data}
+	msg := &logging.Message{
+		Level: logging.Level(r.varint()),
+		Time:  time.Unix(0, r.varint()),
+	}
+	logger, file := r.uvarint(), r.uvarint()
+	msg.Line = int(r.uvarint())
+	msg.Traced = r.uvarint()&flagTraced != 0
+	msg.Msg = r.string()
+	msg.Stack = r.string()
+	for count := r.uvarint(); count > 0 && r.err == nil; count-- {
+		msg.Errors = append(msg.Errors, errors.New(r.string()))
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	name, err := d.lookup(logger)
+	if err != nil {
+		return nil, err
+	}
+	if msg.File, err = d.lookup(file); err != nil {
+		return nil, err
+	}
+	if name == "" || name == d.Hierarchy.Root.Name {
+		msg.Logger = d.Hierarchy.Root
+	} else {
+		msg.Logger = d.Hierarchy.Get(name)
+	}
+	return msg, nil
+}
+
+// Outputter implements logging.Outputter by appending encoded messages to a file. It is safe for concurrent use.
+type Outputter struct {
+	lock    sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *Encoder
+	// Closed to stop the goroutine started by FlushEvery.
+	stop chan struct{}
+}
+
+// Opens a file for appending encoded messages, writing the header if the file is new. If the file ends with a record
+// that was only partly written, the record is cut off first. If bufferSize is positive, messages are buffered in
+// memory until the buffer is full, or until Flush or Close is called (see also FlushEvery).
+func Open(path string, bufferSize int) (*Outputter, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := repair(path, file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	o := &Outputter{file: file}
+	if bufferSize > 0 {
+		o.writer = bufio.NewWriterSize(file, bufferSize)
+		o.encoder = NewEncoder(o.writer)
+	} else {
+		o.encoder = NewEncoder(file)
+	}
+	return o, nil
+}
+
+// Prepares an opened log for appending: cuts off a partly written record at its end, and writes the header if the log
+// is empty.
+func repair(path string, file *os.File) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	length, torn, err := completeLength(input)
+	input.Close()
+	if err != nil {
+		return err
+	}
+	if torn {
+		if err := file.Truncate(length); err != nil {
+			return err
+		}
+	}
+	if length == 0 {
+		return WriteHeader(file)
+	}
+	return nil
+}
+
+// Counts the bytes that have been read.
+type countingReader struct {
+	*bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.Reader.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// Returns the length of a log up to the end of its last complete record, or zero if not even the header is complete,
+// and reports whether the log continues with an incomplete record. Only the record lengths are read, so a log that is
+// corrupt rather than incomplete is left for the Decoder to report.
+func completeLength(r io.Reader) (length int64, torn bool, err error) {
+	c := &countingReader{Reader: bufio.NewReader(r)}
+	magic := make([]byte, len(Magic))
+	n, err := io.ReadFull(c, magic)
+	if string(magic[:n]) != Magic[:n] {
+		return 0, false, ErrNotBinlog
+	} else if err != nil {
+		return 0, n > 0, nil
+	}
+	for {
+		length = c.n
+		size, err := binary.ReadUvarint(c)
+		if err == io.EOF {
+			return length, false, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return length, true, nil
+		} else if err != nil || size == 0 || size > maxRecord {
+			return length, false, nil
+		}
+		discarded, err := c.Discard(int(size))
+		c.n += int64(discarded)
+		if err == io.EOF {
+			return length, true, nil
+		} else if err != nil {
+			return length, false, err
+		}
+	}
+}
+
+// Starts a goroutine that writes buffered messages to the file at the given interval, until the Outputter is closed,
+// so that messages are not kept in memory for long when few are logged. Errors are returned by the next call to
+// OutputErr. FlushEvery does nothing if the Outputter is not buffered, or if it has already been called.
+func (o *Outputter) FlushEvery(interval time.Duration) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.writer == nil || o.file == nil || o.stop != nil || interval <= 0 {
+		return
+	}
+	o.stop = make(chan struct{})
+	go o.flushLoop(interval, o.stop)
+}
+
+func (o *Outputter) flushLoop(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			o.Flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Implements logging.Outputter.
+func (o *Outputter) Output(msg *logging.Message) {
+	o.OutputErr(msg)
+}
+
+// Implements logging.ErrorOutputter.
+func (o *Outputter) OutputErr(msg *logging.Message) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.file == nil {
+		return logging.ErrOutputClosed
+	}
+	return o.encoder.Encode(msg)
+}
+
+// Writes any buffered messages to the file.
+func (o *Outputter) Flush() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.writer == nil || o.file == nil {
+		return nil
+	}
+	return o.writer.Flush()
+}
+
+// Writes any buffered messages and closes the file.
+func (o *Outputter) Close() error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	if o.stop != nil {
+		close(o.stop)
+	}
+	var err error
+	if o.writer != nil {
+		err = o.writer.Flush()
+	}
+	if cerr := o.file.Close(); err == nil {
+		err = cerr
+	}
+	o.file = nil
+	return err
+}
+
+var binaryPlugin = logging.OutputPluginFunc(func(options map[string]string) (logging.Outputter, error) {
+	path := options["file"]
+	if path == "" {
+		return nil, errors.New("file option not specified")
+	}
+	bufferSize := 0
+	if size, ok := options["buffer_size"]; ok {
+		var err error
+		if bufferSize, err = strconv.Atoi(size); err != nil || bufferSize < 0 {
+			return nil, fmt.Errorf("invalid buffer_size: %s", size)
+		}
+	}
+	interval := time.Second
+	if value, ok := options["flush_interval"]; ok {
+		var err error
+		if interval, err = time.ParseDuration(value); err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid flush_interval: %s", value)
+		}
+	}
+	o, err := Open(path, bufferSize)
+	if err != nil {
+		return nil, err
+	}
+	o.FlushEvery(interval)
+	return o, nil
+})
+
+func init() {
+	logging.RegisterOutputPlugin("binary", binaryPlugin)
+	logging.RegisterPluginOptions("binary", "file", "buffer_size", "flush_interval")
+}
@@ -0,0 +1,286 @@
+package binlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decodeAll(t *testing.T, r io.Reader) []*logging.Message {
+	d := NewDecoder(r)
+	var msgs []*logging.Message
+	for {
+		msg, err := d.Decode()
+		if err == io.EOF {
+			return msgs
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	h := logging.NewHierarchy()
+	msgs := []*logging.Message{
+		{Level: logging.Info, Msg: "started", Time: time.Unix(1700000000, 1), File: "/src/main.go", Line: 10, Logger: h.Root},
+		{Level: logging.Error, Msg: "failed", Time: time.Unix(1700000001, 2), File: "/src/db.go", Line: 20, Logger: h.Get("app.db"),
+			Stack: "goroutine 1", Errors: []error{errors.New("timeout"), errors.New("refused")}, Traced: true},
+		{Level: logging.Debug, Msg: "", Time: time.Unix(1700000002, 3), File: "/src/db.go", Line: 21, Logger: h.Get("app.db")},
+	}
+	var buf bytes.Buffer
+	WriteHeader(&buf)
+	e := NewEncoder(&buf)
+	for _, msg := range msgs {
+		if err := e.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	decoded := decodeAll(t, &buf)
+	if len(decoded) != len(msgs) {
+		t.Fatalf("expected %d messages, got %d", len(msgs), len(decoded))
+	}
+	for i, msg := range msgs {
+		got := decoded[i]
+		if got.Level != msg.Level || got.Msg != msg.Msg || !got.Time.Equal(msg.Time) || got.File != msg.File ||
+			got.Line != msg.Line || got.Stack != msg.Stack || got.Traced != msg.Traced || got.Logger.Name != msg.Logger.Name {
+			t.Errorf("message %d does not match: %+v", i, got)
+		}
+		if fmt.Sprint(got.Errors) != fmt.Sprint(msg.Errors) {
+			t.Errorf("errors of message %d do not match: %v", i, got.Errors)
+		}
+	}
+	if decoded[1].Logger != decoded[2].Logger || decoded[0].Logger.Parent() != nil {
+		t.Error("decoded loggers are not shared")
+	}
+}
+
+func TestInterning(t *testing.T) {
+	h := logging.NewHierarchy()
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	msg := &logging.Message{Level: logging.Info, Msg: "x", File: "/a/very/long/path/to/some/file.go", Logger: h.Get("some.logger")}
+	e.Encode(msg)
+	first := buf.Len()
+	e.Encode(msg)
+	if second := buf.Len() - first; second >= first/2 {
+		t.Errorf("repeated strings were not interned: %d bytes, then %d", first, second)
+	}
+	if strings.Count(buf.String(), "file.go") != 1 {
+		t.Error("file name written more than once")
+	}
+
+	// When the table is full, it is reset and strings are defined again
+	var many bytes.Buffer
+	WriteHeader(&many)
+	e = NewEncoder(&many)
+	for i := 0; i <= maxStrings; i++ {
+		e.Encode(&logging.Message{Msg: "m", File: fmt.Sprint("file", i), Logger: h.Root})
+	}
+	decoded := decodeAll(t, &many)
+	if len(decoded) != maxStrings+1 || decoded[maxStrings].File != fmt.Sprint("file", maxStrings) {
+		t.Errorf("unexpected messages after the string table was reset")
+	}
+
+	// The logger name is defined again when the table is reset while interning the file name
+	many.Reset()
+	WriteHeader(&many)
+	e = NewEncoder(&many)
+	logger := h.Get("app")
+	for i := 0; i < 2*maxStrings; i++ {
+		e.Encode(&logging.Message{Msg: "m", File: fmt.Sprintf("f%d.go", i), Logger: logger})
+	}
+	for i, msg := range decodeAll(t, &many) {
+		if msg.Logger.Name != "app" || msg.File != fmt.Sprintf("f%d.go", i) {
+			t.Fatalf("unexpected message %d: logger %s, file %s", i, msg.Logger.Name, msg.File)
+		}
+	}
+}
+
+// Fails every write after the first n.
+type failingWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) {
+	if f.n == 0 {
+		return 0, errors.New("disk full")
+	}
+	f.n--
+	return f.Buffer.Write(p)
+}
+
+func TestWriteFailure(t *testing.T) {
+	h := logging.NewHierarchy()
+	w := &failingWriter{n: 1}
+	WriteHeader(&w.Buffer)
+	e := NewEncoder(w)
+	e.Encode(&logging.Message{Msg: "first", File: "a.go", Logger: h.Get("app")})
+	if err := e.Encode(&logging.Message{Msg: "lost", File: "b.go", Logger: h.Get("app")}); err == nil {
+		t.Fatal("expected a write error")
+	}
+	w.n = 1
+	if err := e.Encode(&logging.Message{Msg: "second", File: "b.go", Logger: h.Get("app")}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The strings of the lost message are defined again
+	decoded := decodeAll(t, &w.Buffer)
+	if len(decoded) != 2 || decoded[1].Msg != "second" || decoded[1].File != "b.go" || decoded[1].Logger.Name != "app" {
+		t.Errorf("unexpected messages after a failed write: %v", decoded)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	if _, err := NewDecoder(strings.NewReader("not a log")).Decode(); err != ErrNotBinlog {
+		t.Errorf("expected ErrNotBinlog, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	WriteHeader(&buf)
+	NewEncoder(&buf).Encode(&logging.Message{Msg: "hello", Logger: logging.NewHierarchy().Root})
+	data := buf.Bytes()
+	if _, err := NewDecoder(bytes.NewReader(data[:len(data)-2])).Decode(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	data = append([]byte(Magic), 2, recordMessage, 0)
+	if _, err := NewDecoder(bytes.NewReader(data)).Decode(); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("expected a corrupt log error, got %v", err)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.binlog")
+	config := `
+  [loggers]
+  root = INFO, binlog
+
+  [binlog]
+  type = binary
+  file = ` + path + `
+  buffer_size = 4096
+  `
+	for _, text := range []string{"first", "second"} {
+		h := logging.NewHierarchy()
+		if err := h.SetupReader(strings.NewReader(config)); err != nil {
+			t.Fatal(err)
+		}
+		h.Get("app").Info(text)
+		if info, _ := os.Stat(path); text == "first" && info.Size() != int64(len(Magic)) {
+			t.Errorf("message was not buffered: %d bytes", info.Size())
+		}
+		if err := h.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The second hierarchy appended to the file, with a string table of its own
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var texts []string
+	for _, msg := range decodeAll(t, file) {
+		texts = append(texts, msg.Logger.Name+": "+msg.Msg)
+	}
+	if !reflect.DeepEqual(texts, []string{"app: first", "app: second"}) {
+		t.Errorf("unexpected messages: %v", texts)
+	}
+
+	o, err := Open(filepath.Join(t.TempDir(), "closed.binlog"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Close()
+	if err := o.OutputErr(&logging.Message{}); err != logging.ErrOutputClosed {
+		t.Errorf("expected ErrOutputClosed, got %v", err)
+	}
+}
+
+func TestTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "torn.binlog")
+	h := logging.NewHierarchy()
+	o, err := Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Output(&logging.Message{Msg: "complete", Logger: h.Get("app")})
+	o.Output(&logging.Message{Msg: "torn", Logger: h.Get("app")})
+	o.Close()
+
+	// Cut the last record short, as if the program had crashed while writing it
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-2); err != nil {
+		t.Fatal(err)
+	}
+	if o, err = Open(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	o.Output(&logging.Message{Msg: "appended", Logger: h.Get("app")})
+	o.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var texts []string
+	for _, msg := range decodeAll(t, file) {
+		texts = append(texts, msg.Msg)
+	}
+	if !reflect.DeepEqual(texts, []string{"complete", "appended"}) {
+		t.Errorf("unexpected messages: %v", texts)
+	}
+
+	// A torn header is written again, and other files are not appended to
+	for contents, expected := range map[string]error{"GL": nil, "text file\n": ErrNotBinlog} {
+		path := filepath.Join(t.TempDir(), "other.binlog")
+		os.WriteFile(path, []byte(contents), 0644)
+		o, err := Open(path, 0)
+		if err != expected {
+			t.Errorf("unexpected error for %q: %v", contents, err)
+		}
+		if err == nil {
+			o.Close()
+			if data, _ := os.ReadFile(path); string(data) != Magic {
+				t.Errorf("unexpected log after a torn header: %q", data)
+			}
+		}
+	}
+}
+
+func TestFlushEvery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interval.binlog")
+	output, err := binaryPlugin.CreateOutputter(map[string]string{"file": path, "buffer_size": "4096", "flush_interval": "10ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := output.(*Outputter)
+	defer o.Close()
+	o.Output(&logging.Message{Msg: "buffered", Logger: logging.NewHierarchy().Root})
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if info, _ := os.Stat(path); info.Size() > int64(len(Magic)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("buffered message was not flushed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := binaryPlugin.CreateOutputter(map[string]string{"file": path, "flush_interval": "soon"}); err == nil {
+		t.Error("expected an error for an invalid flush_interval")
+	}
+}
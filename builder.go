@@ -0,0 +1,261 @@
+package logging
+
+import (
+	"errors"
+	"strings"
+)
+
+// A Builder declares a logging configuration in code. Loggers and outputs are added to the Builder, and are then
+// validated and applied together by Apply, in the same way as SetupConfig:
+//
+//	err := logging.NewBuilder().
+//		Outputter("memory", myOutputter).
+//		Output("console", logging.ConsoleOutput{
+//			Stream:        "stderr",
+//			FormatOptions: logging.FormatOptions{Format: "[$level] $msg"},
+//		}).
+//		Logger("root", logging.LoggerDef{Threshold: logging.Info, Outputs: []string{"console"}}).
+//		Apply()
+type Builder struct {
+	loggers    map[string]loggerConfig
+	outputs    []PluginConfig
+	outputters map[string]Outputter
+	errs       []string
+}
+
+// Returns a new, empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{
+		loggers:    make(map[string]loggerConfig),
+		outputters: make(map[string]Outputter),
+	}
+}
+
+// LoggerDef declares the settings of a logger.
+type LoggerDef struct {
+	// The logger's threshold, which must be one of the standard levels.
+	Threshold Level
+	// The level at which stack traces are captured, or Undefined to disable them.
+	StackTrace Level
+	// Whether messages are propagated to the parent logger's outputs.
+	NoPropagate bool
+	// The level at which recovered panics are logged, or Undefined to inherit it (see Logger.SetPanicLevel).
+	PanicLevel Level
+	// The action taken after a recovered panic has been logged (see Logger.SetOnPanic).
+	OnPanic PanicAction
+	// The names of the outputs that the logger sends messages to. A name may be followed by "@LEVEL" to give the output a
+	// threshold for this logger only, as in the "loggers" section of an INI file.
+	Outputs []string
+}
+
+// An OutputDef declares an output. Its options are passed to the plugin named by the "type" option.
+type OutputDef interface {
+	Options() map[string]string
+}
+
+// OutputOptions contains the options that are common to all outputs.
+type OutputOptions struct {
+	// Messages below this level are not sent to the output. Undefined means that all messages are sent.
+	Threshold Level
+	// Messages at or above this level have stack traces captured for the output. Undefined disables stack traces.
+	StackTrace Level
+}
+
+func (o OutputOptions) options(pluginType string) map[string]string {
+	options := map[string]string{"type": pluginType}
+	if o.Threshold != Undefined {
+		options["threshold"] = o.Threshold.String()
+	}
+	if o.StackTrace != Undefined {
+		options["stacktrace"] = o.StackTrace.String()
+	}
+	return options
+}
+
+// FormatOptions contains the options used to create a Formatter (see CreateFormatter).
+type FormatOptions struct {
+	// The name of the formatter plugin. Defaults to "basic".
+	Formatter string
+	// The template used by the basic formatter.
+	Format string
+}
+
+func (f FormatOptions) addTo(options map[string]string) map[string]string {
+	if f.Formatter != "" {
+		options["formatter"] = f.Formatter
+	}
+	if f.Format != "" {
+		options["format"] = f.Format
+	}
+	return options
+}
+
+// ConsoleOutput declares a "console" output.
+type ConsoleOutput struct {
+	OutputOptions
+	FormatOptions
+	// Either "stdout", "stderr" or a file descriptor number.
+	Stream string
+}
+
+// Implements OutputDef.
+func (c ConsoleOutput) Options() map[string]string {
+	options := c.FormatOptions.addTo(c.OutputOptions.options("console"))
+	options["stream"] = c.Stream
+	return options
+}
+
+// FileOutput declares a "file" output.
+type FileOutput struct {
+	OutputOptions
+	FormatOptions
+	// The path of the file to append messages to.
+	File string
+}
+
+// Implements OutputDef.
+func (f FileOutput) Options() map[string]string {
+	options := f.FormatOptions.addTo(f.OutputOptions.options("file"))
+	options["file"] = f.File
+	return options
+}
+
+// TeeOutput declares a "tee" output.
+type TeeOutput struct {
+	OutputOptions
+	Outputs []string
+}
+
+// Implements OutputDef.
+func (t TeeOutput) Options() map[string]string {
+	options := t.OutputOptions.options("tee")
+	options["outputs"] = strings.Join(t.Outputs, ", ")
+	return options
+}
+
+// FallbackOutput declares a "fallback" output.
+type FallbackOutput struct {
+	OutputOptions
+	Primary   string
+	Secondary string
+}
+
+// Implements OutputDef.
+func (f FallbackOutput) Options() map[string]string {
+	options := f.OutputOptions.options("fallback")
+	options["primary"] = f.Primary
+	options["secondary"] = f.Secondary
+	return options
+}
+
+// RoundRobinOutput declares a "roundrobin" output.
+type RoundRobinOutput struct {
+	OutputOptions
+	Outputs []string
+}
+
+// Implements OutputDef.
+func (r RoundRobinOutput) Options() map[string]string {
+	options := r.OutputOptions.options("roundrobin")
+	options["outputs"] = strings.Join(r.Outputs, ", ")
+	return options
+}
+
+// PluginOutput declares an output of any type, using plugin options directly.
+type PluginOutput map[string]string
+
+// Implements OutputDef.
+func (p PluginOutput) Options() map[string]string {
+	return p
+}
+
+func (b *Builder) addName(name string) bool {
+	if b.outputters[name] != nil {
+		b.errs = append(b.errs, "duplicate output: "+name)
+		return false
+	}
+	for _, output := range b.outputs {
+		if output.Name == name {
+			b.errs = append(b.errs, "duplicate output: "+name)
+			return false
+		}
+	}
+	return true
+}
+
+// Declares a logger. The name "root" refers to the Root logger.
+func (b *Builder) Logger(name string, def LoggerDef) *Builder {
+	if _, ok := b.loggers[name]; ok {
+		b.errs = append(b.errs, "duplicate logger: "+name)
+		return b
+	}
+	if _, ok := levelStrings[def.Threshold]; !ok {
+		b.errs = append(b.errs, "invalid threshold for logger "+name+": "+def.Threshold.String())
+	}
+	if _, ok := levelStrings[def.StackTrace]; !ok && def.StackTrace != Undefined {
+		b.errs = append(b.errs, "invalid stacktrace level for logger "+name+": "+def.StackTrace.String())
+	}
+	if _, ok := levelStrings[def.PanicLevel]; !ok && def.PanicLevel != Undefined {
+		b.errs = append(b.errs, "invalid panic level for logger "+name+": "+def.PanicLevel.String())
+	}
+	if _, ok := panicActionStrings[def.OnPanic]; !ok && def.OnPanic != PanicInherit {
+		b.errs = append(b.errs, "invalid panic action for logger "+name+": "+def.OnPanic.String())
+	}
+	lc := loggerConfig{
+		Threshold:   def.Threshold,
+		StackTrace:  def.StackTrace,
+		NoPropagate: def.NoPropagate,
+		PanicLevel:  def.PanicLevel,
+		OnPanic:     def.OnPanic,
+	}
+	for _, ref := range def.Outputs {
+		output, err := parseLoggerOutput(ref)
+		if err != nil {
+			b.errs = append(b.errs, err.Error())
+		}
+		lc.Outputs = append(lc.Outputs, output)
+	}
+	b.loggers[name] = lc
+	return b
+}
+
+// Declares an output, which will be created by its plugin when the Builder is applied.
+func (b *Builder) Output(name string, def OutputDef) *Builder {
+	if b.addName(name) {
+		b.outputs = append(b.outputs, PluginConfig{
+			Name:    name,
+			Options: def.Options(),
+		})
+	}
+	return b
+}
+
+// Declares an output that uses an existing Outputter.
+func (b *Builder) Outputter(name string, output Outputter) *Builder {
+	if b.addName(name) {
+		b.outputters[name] = output
+	}
+	return b
+}
+
+// Validates the declared configuration in the same way as SetupConfig (see ValidateConfig) and creates its outputs. If
+// there are no errors, the configuration of the hierarchy is replaced, otherwise it is left unchanged.
+func (b *Builder) ApplyTo(h *Hierarchy) error {
+	if len(b.errs) > 0 {
+		return errors.New(strings.Join(b.errs, "; "))
+	}
+	loggers := make(map[string]string, len(b.loggers))
+	for name, lc := range b.loggers {
+		loggers[name] = lc.String()
+	}
+	plan, err := h.prepareConfig(&StructuredConfig{Loggers: loggers, Outputs: b.outputs}, b.outputters)
+	if err != nil {
+		return err
+	}
+	return plan.apply()
+}
+
+// Applies the declared configuration to the Default hierarchy (see ApplyTo).
+func (b *Builder) Apply() error {
+	return b.ApplyTo(Default)
+}
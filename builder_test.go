@@ -0,0 +1,52 @@
+package logging
+
+import (
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	defer resetLoggers()
+	var logs, more msgSlice
+	err := NewBuilder().
+		Outputter("mock", &logs).
+		Outputter("more", &more).
+		Output("both", TeeOutput{Outputs: []string{"mock", "more"}, OutputOptions: OutputOptions{Threshold: Warn}}).
+		Logger("root", LoggerDef{Threshold: Info, Outputs: []string{"mock"}}).
+		Logger("builder", LoggerDef{Threshold: Debug, Outputs: []string{"both"}, NoPropagate: true}).
+		Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Get("builder").Debug("filtered by output")
+	Get("builder").Warn("warning")
+	Get("other").Info("info")
+	if len(logs) != 2 || logs[0].Msg != "warning" || logs[1].Msg != "info" {
+		t.Errorf("unexpected messages: %v", logs)
+	}
+	if len(more) != 1 || more[0].Msg != "warning" {
+		t.Errorf("unexpected messages: %v", more)
+	}
+
+	// Invalid configurations must not change anything
+	invalid := []*Builder{
+		NewBuilder().Logger("root", LoggerDef{Threshold: Level(42)}),
+		NewBuilder().Logger("root", LoggerDef{Threshold: Info, Outputs: []string{"missing"}}),
+		NewBuilder().Outputter("mock", &logs).Outputter("mock", &more),
+		NewBuilder().Logger("root", LoggerDef{Threshold: Info}).Logger("root", LoggerDef{Threshold: Warn}),
+		NewBuilder().Output("console", ConsoleOutput{Stream: "stderr"}),
+		// Problems that are only found by ValidateConfig
+		NewBuilder().Outputter("mock", &logs).Output("odd", PluginOutput{"type": "console", "stream": "stderr", "colour": "yes"}).
+			Logger("root", LoggerDef{Threshold: Info, Outputs: []string{"mock", "odd"}}),
+	}
+	for i, b := range invalid {
+		if err := b.Apply(); err == nil {
+			t.Errorf("invalid builder %d was applied", i)
+		}
+	}
+	logs = nil
+	Get("other").Info("still configured")
+	if len(logs) != 1 {
+		t.Errorf("configuration was changed by an invalid builder")
+	}
+}
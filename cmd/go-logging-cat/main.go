@@ -0,0 +1,165 @@
+// Command go-logging-cat decodes logs written by the "binary" output (see the binlog package), and prints their messages
+// with any go-logging formatter.
+//
+// Usage:
+//
+//	go-logging-cat [flags] [file ...]
+//
+// Files are read in order, and standard input is read if no files (or "-") are given. Messages can be filtered by
+// level, logger and time:
+//
+//	go-logging-cat -level WARN -logger app.db -since 1h /var/log/myapp.binlog
+//	go-logging-cat -formatter logfmt -until 2024-01-02T15:00:00Z /var/log/myapp.binlog
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"github.com/vaughan0/go-logging/binlog"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// The format used by the basic formatter when no -format is given.
+const defaultFormat = "$datetime $level ($logger) $msg"
+
+// Decides which messages are printed.
+type filter struct {
+	level  logging.Level
+	logger string
+	since  time.Time
+	until  time.Time
+}
+
+func (f *filter) match(msg *logging.Message) bool {
+	if f.level != logging.Undefined && msg.Level < f.level {
+		return false
+	}
+	if f.logger != "" {
+		name := msg.Logger.Name
+		if name != f.logger && !strings.HasPrefix(name, f.logger+".") {
+			return false
+		}
+	}
+	if !f.since.IsZero() && msg.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !msg.Time.Before(f.until) {
+		return false
+	}
+	return true
+}
+
+// Parses a time as RFC 3339, or as a duration before now (such as "15m").
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s (expected RFC 3339 or a duration)", value)
+}
+
+// Prints the matching messages of a log.
+func cat(input io.Reader, f *filter, formatter logging.Formatter, output io.Writer) error {
+	decoder := binlog.NewDecoder(input)
+	for {
+		msg, err := decoder.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !f.match(msg) {
+			continue
+		}
+		if _, err := io.WriteString(output, formatter.Format(msg)+"\n"); err != nil {
+			return err
+		}
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("go-logging-cat", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	level := flags.String("level", "", "only print messages at or above this `level`")
+	logger := flags.String("logger", "", "only print messages from this logger and its descendants")
+	since := flags.String("since", "", "only print messages logged at or after this `time` (RFC 3339, or a duration ago)")
+	until := flags.String("until", "", "only print messages logged before this `time` (RFC 3339, or a duration ago)")
+	formatterName := flags.String("formatter", "basic", "the formatter to print messages with: basic, json or logfmt")
+	format := flags.String("format", defaultFormat, "the template used by the basic formatter")
+	timeFormat := flags.String("timeformat", "", "the time layout used by the json and logfmt formatters")
+	keys := flags.String("keys", "", "the comma-separated keys written by the logfmt formatter")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: go-logging-cat [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	err := func() (err error) {
+		f := &filter{logger: *logger}
+		if *level != "" {
+			if f.level, err = logging.ParseLevel(*level); err != nil {
+				return
+			}
+		}
+		now := time.Now()
+		if *since != "" {
+			if f.since, err = parseTime(*since, now); err != nil {
+				return
+			}
+		}
+		if *until != "" {
+			if f.until, err = parseTime(*until, now); err != nil {
+				return
+			}
+		}
+		options := map[string]string{"formatter": *formatterName, "format": *format, "timeformat": *timeFormat}
+		if *keys != "" {
+			options["keys"] = *keys
+		}
+		formatter, err := logging.CreateFormatter(options)
+		if err != nil {
+			return
+		}
+
+		files := flags.Args()
+		if len(files) == 0 {
+			files = []string{"-"}
+		}
+		for _, name := range files {
+			input := stdin
+			if name != "-" {
+				file, err := os.Open(name)
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				input = file
+			}
+			if err := cat(input, f, formatter, stdout); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		fmt.Fprintln(stderr, "go-logging-cat:", err)
+		return 1
+	}
+	return 0
+}
+
+func main() {
+	stdout := bufio.NewWriter(os.Stdout)
+	status := run(os.Args[1:], os.Stdin, stdout, os.Stderr)
+	stdout.Flush()
+	os.Exit(status)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"github.com/vaughan0/go-logging"
+	"github.com/vaughan0/go-logging/binlog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeLog(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "test.binlog")
+	o, err := binlog.Open(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	h := logging.NewHierarchy()
+	base := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	msgs := []*logging.Message{
+		{Level: logging.Info, Msg: "starting", Logger: h.Root, Time: base},
+		{Level: logging.Debug, Msg: "connecting", Logger: h.Get("app.db"), Time: base.Add(time.Minute)},
+		{Level: logging.Warn, Msg: "slow query", Logger: h.Get("app.db"), Time: base.Add(2 * time.Minute)},
+		{Level: logging.Error, Msg: "request failed", Logger: h.Get("app.http"), Time: base.Add(3 * time.Minute)},
+		{Level: logging.Warn, Msg: "retrying", Logger: h.Get("app.dbx"), Time: base.Add(4 * time.Minute)},
+	}
+	for _, msg := range msgs {
+		if err := o.OutputErr(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	path := writeLog(t)
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"-format", "$level $msg"}, "INFO starting\nDEBUG connecting\nWARN slow query\nERROR request failed\nWARN retrying\n"},
+		{[]string{"-format", "$msg", "-level", "warn"}, "slow query\nrequest failed\nretrying\n"},
+		{[]string{"-format", "$msg", "-logger", "app.db"}, "connecting\nslow query\n"},
+		{[]string{"-format", "$msg", "-since", "2024-01-02T15:02:00Z", "-until", "2024-01-02T15:04:00Z"}, "slow query\nrequest failed\n"},
+		{[]string{"-formatter", "logfmt", "-keys", "level,logger,msg", "-logger", "app.http"}, "level=ERROR logger=app.http msg=\"request failed\"\n"},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if status := run(append(test.args, path), nil, &stdout, &stderr); status != 0 {
+			t.Errorf("%v failed with status %d: %s", test.args, status, stderr.String())
+			continue
+		}
+		if stdout.String() != test.expected {
+			t.Errorf("unexpected output for %v:\n%s", test.args, stdout.String())
+		}
+	}
+
+	// Standard input is read if no files are given
+	data, _ := os.ReadFile(path)
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"-format", "$msg", "-level", "ERROR"}, bytes.NewReader(data), &stdout, &stderr); status != 0 ||
+		stdout.String() != "request failed\n" {
+		t.Errorf("unexpected output from standard input: %d %q %s", status, stdout.String(), stderr.String())
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	invalid := [][]string{
+		{"-level", "LOUD"},
+		{"-since", "yesterday"},
+		{"-formatter", "xml"},
+		{filepath.Join(t.TempDir(), "missing.binlog")},
+	}
+	for _, args := range invalid {
+		var stdout, stderr bytes.Buffer
+		if status := run(args, strings.NewReader(""), &stdout, &stderr); status != 1 || stderr.Len() == 0 {
+			t.Errorf("expected %v to fail, got status %d", args, status)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	if status := run(nil, strings.NewReader("plain text"), &stdout, &stderr); status != 1 ||
+		!strings.Contains(stderr.String(), binlog.ErrNotBinlog.Error()) {
+		t.Errorf("unexpected result for a text file: %d %s", status, stderr.String())
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	if result, err := parseTime("90m", now); err != nil || !result.Equal(now.Add(-90*time.Minute)) {
+		t.Errorf("unexpected result for a duration: %v %v", result, err)
+	}
+	if result, err := parseTime("2024-01-01T00:00:00+02:00", now); err != nil || result.UTC().Hour() != 22 {
+		t.Errorf("unexpected result for an RFC 3339 time: %v %v", result, err)
+	}
+}
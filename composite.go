@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"sync"
+)
+
+// TeeOutputter implements Outputter by sending every message to each of a list of Outputters.
+type TeeOutputter []Outputter
+
+// Implements Outputter.
+func (t TeeOutputter) Output(msg *Message) {
+	t.OutputErr(msg)
+}
+
+// Implements ErrorOutputter. The message is sent to every Outputter, even if some of them fail. The first error that
+// occurred is returned.
+func (t TeeOutputter) OutputErr(msg *Message) (err error) {
+	for _, output := range t {
+		if e := OutputErr(output, StackFor(output, msg)); e != nil && err == nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Implements StackWanter.
+func (t TeeOutputter) WantsStack() bool {
+	for _, output := range t {
+		if wantsStack(output) {
+			return true
+		}
+	}
+	return false
+}
+
+// FallbackOutputter implements Outputter by sending messages to a primary Outputter, and then to a secondary Outputter
+// if the primary reports an error.
+type FallbackOutputter struct {
+	Primary   Outputter
+	Secondary Outputter
+}
+
+// Implements Outputter.
+func (f FallbackOutputter) Output(msg *Message) {
+	f.OutputErr(msg)
+}
+
+// Implements ErrorOutputter. An error is only returned if both the primary and secondary Outputters fail.
+func (f FallbackOutputter) OutputErr(msg *Message) error {
+	if err := OutputErr(f.Primary, StackFor(f.Primary, msg)); err == nil {
+		return nil
+	}
+	return OutputErr(f.Secondary, StackFor(f.Secondary, msg))
+}
+
+// Implements StackWanter.
+func (f FallbackOutputter) WantsStack() bool {
+	return wantsStack(f.Primary) || wantsStack(f.Secondary)
+}
+
+// RoundRobinOutputter implements Outputter by distributing messages between a list of Outputters in turn. If an
+// Outputter reports an error, the message is retried on the following Outputters.
+type RoundRobinOutputter struct {
+	outputs []Outputter
+	next    int
+	lock    sync.Mutex
+}
+
+// Returns a new RoundRobinOutputter which distributes messages between the given Outputters.
+func NewRoundRobinOutputter(outputs ...Outputter) *RoundRobinOutputter {
+	return &RoundRobinOutputter{outputs: outputs}
+}
+
+// Implements Outputter.
+func (r *RoundRobinOutputter) Output(msg *Message) {
+	r.OutputErr(msg)
+}
+
+// Implements ErrorOutputter. An error is only returned if every Outputter fails, in which case the last error is
+// returned.
+func (r *RoundRobinOutputter) OutputErr(msg *Message) (err error) {
+	if len(r.outputs) == 0 {
+		return nil
+	}
+	r.lock.Lock()
+	start := r.next
+	r.next = (r.next + 1) % len(r.outputs)
+	r.lock.Unlock()
+
+	for i := range r.outputs {
+		output := r.outputs[(start+i)%len(r.outputs)]
+		if err = OutputErr(output, StackFor(output, msg)); err == nil {
+			return nil
+		}
+	}
+	return
+}
+
+// Implements StackWanter.
+func (r *RoundRobinOutputter) WantsStack() bool {
+	for _, output := range r.outputs {
+		if wantsStack(output) {
+			return true
+		}
+	}
+	return false
+}
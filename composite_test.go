@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type failOutputter struct{}
+
+func (failOutputter) Output(msg *Message) {}
+
+func (failOutputter) OutputErr(msg *Message) error {
+	return errors.New("failed")
+}
+
+func (f failOutputter) CreateOutputter(options map[string]string) (Outputter, error) {
+	return f, nil
+}
+
+func TestCompositeOutputs(t *testing.T) {
+	var first, second msgSlice
+	RegisterOutputPlugin("mock1", &first)
+	RegisterOutputPlugin("mock2", &second)
+	RegisterOutputPlugin("fail", failOutputter{})
+	config := `
+  [loggers]
+  root = INFO, all
+  tee = INFO, tee, nopropagate
+  fallback = INFO, fallback, nopropagate
+  rr = INFO, rr, nopropagate
+
+  [all]
+  type = tee
+  outputs = tee, fallback
+
+  [tee]
+  type = tee
+  outputs = one, two
+
+  [fallback]
+  type = fallback
+  primary = broken
+  secondary = two
+
+  [rr]
+  type = roundrobin
+  outputs = one, broken, two
+
+  [one]
+  type = mock1
+
+  [two]
+  type = mock2
+
+  [broken]
+  type = fail
+  `
+	if err := SetupReader(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	check := func(logs *msgSlice, msgs ...string) {
+		if len(*logs) != len(msgs) {
+			t.Fatalf("expected %v, got %v", msgs, *logs)
+		}
+		for i, logged := range *logs {
+			if logged.Msg != msgs[i] {
+				t.Fatalf("expected %v, got %v", msgs, *logs)
+			}
+		}
+		*logs = nil
+	}
+
+	Get("tee").Info("a")
+	check(&first, "a")
+	check(&second, "a")
+
+	Get("fallback").Info("b")
+	check(&first)
+	check(&second, "b")
+
+	rr := Get("rr")
+	rr.Info("c")
+	rr.Info("d")
+	rr.Info("e")
+	rr.Info("f")
+	check(&first, "c", "f")
+	check(&second, "d", "e")
+
+	Root.Info("g")
+	check(&first, "g")
+	check(&second, "g", "g")
+}
+
+func TestCompositeCycle(t *testing.T) {
+	config := `
+  [a]
+  type = tee
+  outputs = b
+
+  [b]
+  type = fallback
+  primary = c
+  secondary = a
+
+  [c]
+  type = console
+  stream = stderr
+  format = $msg
+  `
+	err := SetupReader(strings.NewReader(config))
+	var cycle ErrOutputCycle
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected ErrOutputCycle, got %v", err)
+	}
+}
+
+// An output that records whether it was closed, and fails to close.
+type closeFailer struct {
+	closed bool
+}
+
+func (c *closeFailer) Output(msg *Message) {}
+
+func (c *closeFailer) Close() error {
+	c.closed = true
+	return errors.New("close failed")
+}
+
+func TestReplacedOutputsClosed(t *testing.T) {
+	h := NewHierarchy()
+	var first closeFailer
+	h.RegisterOutputPlugin("closer", OutputPluginFunc(func(options map[string]string) (Outputter, error) {
+		return &first, nil
+	}))
+	h.RegisterOutputPlugin("null", OutputPluginFunc(func(options map[string]string) (Outputter, error) {
+		return OutputterFunc(func(msg *Message) {}), nil
+	}))
+	if err := h.SetupConfig(IniConfig{"loggers": {"root": "INFO, out"}, "out": {"type": "closer"}}); err != nil {
+		t.Fatal(err)
+	}
+	err := h.SetupConfig(IniConfig{"loggers": {"root": "INFO, out"}, "out": {"type": "null"}})
+	if !first.closed {
+		t.Error("replaced output was not closed before SetupConfig returned")
+	}
+	if err == nil || !strings.Contains(err.Error(), "close failed") {
+		t.Errorf("expected the close error to be returned, got %v", err)
+	}
+}
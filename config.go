@@ -2,10 +2,13 @@ package logging
 
 import (
 	"errors"
+	"fmt"
 	"github.com/vaughan0/go-ini"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync/atomic"
 )
 
 type Config interface {
@@ -41,30 +44,83 @@ func (o OutputPluginFunc) CreateOutputter(options map[string]string) (Outputter,
 	return o(options)
 }
 
-var outputPlugins = make(map[string]OutputPlugin)
+// A CompositePlugin is responsible for creating Outputters that wrap the outputters of other configuration sections.
+// The resolve function returns the Outputter for a section name, creating it first if necessary.
+type CompositePlugin interface {
+	CreateComposite(options map[string]string, resolve func(name string) (Outputter, error)) (Outputter, error)
+}
+
+// CompositePluginFunc is a utility type that implements CompositePlugin.
+type CompositePluginFunc func(options map[string]string, resolve func(name string) (Outputter, error)) (Outputter, error)
+
+// Implements CompositePlugin.
+func (c CompositePluginFunc) CreateComposite(options map[string]string, resolve func(name string) (Outputter, error)) (Outputter, error) {
+	return c(options, resolve)
+}
 
 // Registers an output plugin by name.
+func (h *Hierarchy) RegisterOutputPlugin(name string, plugin OutputPlugin) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.outputPlugins[name] = plugin
+}
+
+// Registers an output plugin with the Default hierarchy.
 func RegisterOutputPlugin(name string, plugin OutputPlugin) {
-	lock.Lock()
-	defer lock.Unlock()
-	outputPlugins[name] = plugin
+	Default.RegisterOutputPlugin(name, plugin)
+}
+
+// Registers a composite plugin by name. Composite plugins share a namespace with output plugins.
+func (h *Hierarchy) RegisterCompositePlugin(name string, plugin CompositePlugin) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.compositePlugins[name] = plugin
+}
+
+// Registers a composite plugin with the Default hierarchy.
+func RegisterCompositePlugin(name string, plugin CompositePlugin) {
+	Default.RegisterCompositePlugin(name, plugin)
+}
+
+// ErrOutputCycle is returned when output sections refer to each other in a cycle. It contains the name of the section
+// at which the cycle was detected.
+type ErrOutputCycle string
+
+func (e ErrOutputCycle) Error() string {
+	return "logging output refers to itself: " + string(e)
+}
+
+// ErrUnknownOutput is returned when a logger or a composite section refers to an output section that does not exist. It
+// contains the name that was referred to.
+type ErrUnknownOutput string
+
+func (e ErrUnknownOutput) Error() string {
+	return "unknown logging output: " + string(e)
 }
 
-// Loads the appropriate plugin and creates an outputter, given a configuration section.
-func newOutputterConfig(config map[string]string) (Outputter, error) {
+// Loads the appropriate plugin and creates an outputter, given a configuration section. Composite plugins use resolve
+// to obtain the outputters of the sections they refer to.
+func (h *Hierarchy) newOutputterConfig(config map[string]string, resolve func(name string) (Outputter, error)) (Outputter, error) {
 	// Get plugin from the "type" option
 	name, ok := config["type"]
 	if !ok {
 		return nil, ErrTypeNotSpecified
 	}
-	lock.Lock()
-	defer lock.Unlock()
-	plugin := outputPlugins[name]
-	if plugin == nil {
+	h.lock.Lock()
+	plugin := h.outputPlugins[name]
+	composite := h.compositePlugins[name]
+	h.lock.Unlock()
+
+	var output Outputter
+	var err error
+	switch {
+	case plugin != nil:
+		output, err = plugin.CreateOutputter(config)
+	case composite != nil:
+		output, err = composite.CreateComposite(config, resolve)
+	default:
 		return nil, ErrUnknownPlugin(name)
 	}
-
-	output, err := plugin.CreateOutputter(config)
 	if err != nil {
 		return nil, err
 	}
@@ -77,58 +133,292 @@ func newOutputterConfig(config map[string]string) (Outputter, error) {
 			return nil, errors.New("invalid threshold: " + thresh)
 		}
 	}
+
+	// Check for the "stacktrace" option
+	if stack, ok := config["stacktrace"]; ok {
+		if level, ok := reverseLevelStrings[strings.ToUpper(stack)]; ok {
+			output = StackOutputter{level, output}
+		} else {
+			return nil, errors.New("invalid stacktrace level: " + stack)
+		}
+	}
 	return output, nil
 }
 
-func SetupConfig(config Config) (err error) {
-	resetLoggers()
+// Creates an outputter for every plugin section. Sections are created in dependency order, so that a composite
+// section is created after all of the sections it refers to. Predefined outputters may also be referred to by
+// composite sections. The lowest stack trace level required by any section is also returned.
+func (h *Hierarchy) newOutputters(plugins []PluginConfig, predefined map[string]Outputter) (map[string]Outputter, Level, error) {
+	sections := make(map[string]map[string]string)
+	for _, pluginCfg := range plugins {
+		sections[pluginCfg.Name] = pluginCfg.Options
+	}
 
-	// Create outputters
 	outputters := make(map[string]Outputter)
-	for _, pluginCfg := range config.Plugins() {
-		var output Outputter
-		if output, err = newOutputterConfig(pluginCfg.Options); err != nil {
-			return
+	for name, output := range predefined {
+		outputters[name] = output
+	}
+	stackTrace := Undefined
+	resolving := make(map[string]bool)
+	var resolve func(name string) (Outputter, error)
+	resolve = func(name string) (Outputter, error) {
+		if output := outputters[name]; output != nil {
+			return output, nil
+		}
+		options, ok := sections[name]
+		if !ok {
+			return nil, ErrUnknownOutput(name)
+		}
+		if resolving[name] {
+			return nil, ErrOutputCycle(name)
+		}
+		resolving[name] = true
+		output, err := h.newOutputterConfig(options, resolve)
+		resolving[name] = false
+		if err != nil {
+			return nil, err
 		}
-		outputters[pluginCfg.Name] = output
+		if stack, ok := output.(StackOutputter); ok && (stackTrace == Undefined || stack.StackTrace < stackTrace) {
+			stackTrace = stack.StackTrace
+		}
+		outputters[name] = output
+		return output, nil
 	}
 
-	// Setup loggers
-	for name, config := range config.LoggerSettings() {
-		parts := strings.Split(config, ",")
-		for i, part := range parts {
-			parts[i] = strings.TrimSpace(part)
+	for _, pluginCfg := range plugins {
+		if _, err := resolve(pluginCfg.Name); err != nil {
+			closeAll(closers(outputters, predefined))
+			return nil, Undefined, err
 		}
-		level, ok := reverseLevelStrings[strings.ToUpper(parts[0])]
-		if !ok {
-			return errors.New("unknown logging level: " + parts[0])
+	}
+	return outputters, stackTrace, nil
+}
+
+// The parsed settings of a single logger.
+type loggerConfig struct {
+	Threshold   Level
+	StackTrace  Level
+	NoPropagate bool
+	PanicLevel  Level
+	OnPanic     PanicAction
+	Outputs     []loggerOutput
+}
+
+// An output attached to a logger, with an optional threshold of its own.
+type loggerOutput struct {
+	Name      string
+	Threshold Level
+}
+
+// Formats the settings in the form that is parsed by parseLoggerConfig.
+func (lc loggerConfig) String() string {
+	parts := []string{lc.Threshold.String()}
+	for _, output := range lc.Outputs {
+		if output.Threshold != Undefined {
+			parts = append(parts, output.Name+"@"+output.Threshold.String())
+		} else {
+			parts = append(parts, output.Name)
 		}
-		// Get the logger by its name, treating "root" as a special name
-		var logger *Logger
-		if name == "root" {
-			logger = Root
+	}
+	if lc.NoPropagate {
+		parts = append(parts, "nopropagate")
+	}
+	if lc.StackTrace != Undefined {
+		parts = append(parts, "stacktrace="+lc.StackTrace.String())
+	}
+	if lc.PanicLevel != Undefined {
+		parts = append(parts, "paniclevel="+lc.PanicLevel.String())
+	}
+	if lc.OnPanic != PanicInherit {
+		parts = append(parts, "onpanic="+lc.OnPanic.String())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Parses an output reference in the form "name" or "name@LEVEL".
+func parseLoggerOutput(ref string) (lo loggerOutput, err error) {
+	lo.Name = ref
+	if i := strings.LastIndex(ref, "@"); i >= 0 {
+		lo.Name = strings.TrimSpace(ref[:i])
+		level := strings.TrimSpace(ref[i+1:])
+		var ok bool
+		if lo.Threshold, ok = reverseLevelStrings[strings.ToUpper(level)]; !ok {
+			return lo, errors.New("invalid output threshold: " + ref)
+		}
+	}
+	return
+}
+
+// Parses a logger's settings, which consist of a level followed by a comma-separated list of output names and options.
+func parseLoggerConfig(config string) (lc loggerConfig, err error) {
+	parts := strings.Split(config, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	var ok bool
+	if lc.Threshold, ok = reverseLevelStrings[strings.ToUpper(parts[0])]; !ok {
+		return lc, errors.New("unknown logging level: " + parts[0])
+	}
+	// Handle extra options
+	for _, outputKey := range parts[1:] {
+		if outputKey == "nopropagate" {
+			lc.NoPropagate = true
+		} else if strings.HasPrefix(outputKey, "stacktrace=") {
+			stack := strings.TrimSpace(outputKey[len("stacktrace="):])
+			if lc.StackTrace, ok = reverseLevelStrings[strings.ToUpper(stack)]; !ok {
+				return lc, errors.New("invalid stacktrace level: " + stack)
+			}
+		} else if strings.HasPrefix(outputKey, "paniclevel=") {
+			level := strings.TrimSpace(outputKey[len("paniclevel="):])
+			if lc.PanicLevel, ok = reverseLevelStrings[strings.ToUpper(level)]; !ok {
+				return lc, errors.New("invalid panic level: " + level)
+			}
+		} else if strings.HasPrefix(outputKey, "onpanic=") {
+			if lc.OnPanic, err = ParsePanicAction(outputKey[len("onpanic="):]); err != nil {
+				return lc, err
+			}
 		} else {
-			logger = Get(name)
-		}
-		logger.Threshold = level
-		// Handle extra options
-		for _, outputKey := range parts[1:] {
-			if outputKey == "nopropagate" {
-				logger.NoPropagate = true
-			} else {
-				// Assign an outputter
-				if outputter := outputters[outputKey]; outputter != nil {
-					logger.AddOutput(outputter)
-				} else {
-					return errors.New("unknown logging output: " + outputKey)
-				}
+			output, err := parseLoggerOutput(outputKey)
+			if err != nil {
+				return lc, err
 			}
+			lc.Outputs = append(lc.Outputs, output)
 		}
 	}
+	return
+}
+
+// A setupPlan holds a configuration that has been fully validated, and is ready to be applied to the logger hierarchy.
+type setupPlan struct {
+	hierarchy  *Hierarchy
+	outputters map[string]Outputter
+	sections   map[string]map[string]string
+	stackTrace Level
+	loggers    map[string]loggerConfig
+	selectors  []*loggerSelector
+	closers    []io.Closer
+}
+
+// Creates the outputters for a configuration, and checks that every logger refers to known outputs.
+func (h *Hierarchy) newSetupPlan(plugins []PluginConfig, predefined map[string]Outputter, loggers map[string]loggerConfig) (plan *setupPlan, err error) {
+	outputters, stackTrace, err := h.newOutputters(plugins, predefined)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			closeAll(closers(outputters, predefined))
+		}
+	}()
+	exact := make(map[string]loggerConfig)
+	var selectors []*loggerSelector
+	for name, lc := range loggers {
+		for _, output := range lc.Outputs {
+			if outputters[output.Name] == nil {
+				return nil, errors.New("unknown logging output: " + output.Name)
+			}
+		}
+		if !isSelector(name) {
+			exact[name] = lc
+			continue
+		}
+		selector, err := newLoggerSelector(name, lc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid logger selector %s: %v", name, err)
+		}
+		selectors = append(selectors, selector)
+	}
+	sortSelectors(selectors)
+	sections := make(map[string]map[string]string)
+	for _, pluginCfg := range plugins {
+		sections[pluginCfg.Name] = pluginCfg.Options
+	}
+	return &setupPlan{h, outputters, sections, stackTrace, exact, selectors, closers(outputters, predefined)}, nil
+}
+
+// Replaces a logger's settings with the given configuration. The hierarchy's lock must be held.
+func applyLoggerConfig(logger *Logger, lc loggerConfig, outputters map[string]Outputter) {
+	logger.SetThreshold(lc.Threshold)
+	logger.StackTrace = lc.StackTrace
+	logger.SetNoPropagate(lc.NoPropagate)
+	logger.panicLevel = lc.PanicLevel
+	logger.onPanic = lc.OnPanic
+	for _, output := range lc.Outputs {
+		var named Outputter = NewNamedOutputter(output.Name, outputters[output.Name])
+		if output.Threshold != Undefined {
+			named = ThresholdOutputter{output.Threshold, named}
+		}
+		logger.attach(&attachment{named})
+	}
+}
+
+// Replaces the configuration of the logger hierarchy. The outputs of the previous configuration are closed once it has
+// been replaced, and any errors that occur while closing them are returned.
+func (p *setupPlan) apply() error {
+	h := p.hierarchy
+	h.lock.Lock()
+	h.resetLocked()
+	atomic.StoreInt64(&h.outputStackTrace, int64(p.stackTrace))
+	h.configuredSections = p.sections
+	h.selectors = p.selectors
+	h.outputters = p.outputters
+	for name, lc := range p.loggers {
+		// Get the logger by its name, treating "root" as a special name
+		logger := h.Root
+		if name != "root" {
+			logger = h.get(name)
+		}
+		applyLoggerConfig(logger, lc, p.outputters)
+	}
+
+	// Apply selectors to the other existing loggers, and keep them for loggers that are created later
+	h.Root.walk(func(logger *Logger) {
+		if _, ok := p.loggers[logger.Name]; !ok && logger != h.Root {
+			h.selectLogger(logger)
+		}
+	})
+	replaced := h.closers
+	h.closers = p.closers
+	h.invalidate()
+	h.lock.Unlock()
+
+	return closeAll(replaced)
+}
+
+// Validates a configuration, and creates its outputters. The configuration's loggers and composite sections may also
+// refer to the predefined outputters.
+func (h *Hierarchy) prepareConfig(config Config, predefined map[string]Outputter) (*setupPlan, error) {
+	if err := h.validateConfig(config, predefined); err != nil {
+		if errs := err.(ConfigErrors).Errors(); len(errs) > 0 {
+			return nil, errs
+		}
+	}
+	loggers := make(map[string]loggerConfig)
+	for name, settings := range config.LoggerSettings() {
+		lc, err := parseLoggerConfig(settings)
+		if err != nil {
+			return nil, err
+		}
+		loggers[name] = lc
+	}
+	return h.newSetupPlan(config.Plugins(), predefined, loggers)
+}
 
-	Root.configure()
-	configured = true
-	return nil
+// Configures the logging hierarchy. The configuration is validated (see ValidateConfig) and its outputters are created
+// before the current configuration is replaced, so the current configuration is left in place if an error occurs.
+// Warnings found by ValidateConfig are ignored. The outputs of the previous configuration are closed before SetupConfig
+// returns, and if any of them fail to close, the error is returned even though the new configuration is in place.
+func (h *Hierarchy) SetupConfig(config Config) error {
+	plan, err := h.prepareConfig(config, nil)
+	if err != nil {
+		return err
+	}
+	return plan.apply()
+}
+
+// Configures the Default hierarchy (see Hierarchy.SetupConfig).
+func SetupConfig(config Config) error {
+	return Default.SetupConfig(config)
 }
 
 type IniConfig ini.File
@@ -149,31 +439,69 @@ func (i IniConfig) Plugins() (plugins []PluginConfig) {
 }
 
 // Configures the logging hierarchy from an io.Reader, which should return valid INI source code.
-func SetupReader(input io.Reader) (err error) {
+func (h *Hierarchy) SetupReader(input io.Reader) (err error) {
 	file, err := ini.Load(input)
 	if err != nil {
 		return
 	}
-	return SetupConfig(IniConfig(file))
+	return h.SetupConfig(IniConfig(file))
+}
+
+// Configures the Default hierarchy from an io.Reader (see Hierarchy.SetupReader).
+func SetupReader(input io.Reader) error {
+	return Default.SetupReader(input)
 }
 
-// Configures the logging hierarchy from an INI file.
-func SetupFile(filename string) (err error) {
+// Loads a configuration file. The format of the file is chosen by its extension (see RegisterConfigFormat), and files
+// with an unregistered extension are loaded as INI.
+func loadFile(filename string) (Config, error) {
 	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lock.Lock()
+	loader := configFormats[strings.ToLower(filepath.Ext(filename))]
+	lock.Unlock()
+	if loader == nil {
+		iniFile, err := ini.Load(file)
+		if err != nil {
+			return nil, err
+		}
+		return IniConfig(iniFile), nil
+	}
+	return loader(file)
+}
+
+// Configures the logging hierarchy from a file. The format of the file is chosen by its extension (see
+// RegisterConfigFormat), and files with an unregistered extension are loaded as INI. INI files may include other files
+// (see SetupFiles).
+func (h *Hierarchy) SetupFile(filename string) (err error) {
+	config, err := loadFiles(filename)
 	if err != nil {
 		return
 	}
-	return SetupReader(file)
+	return h.SetupConfig(config)
+}
+
+// Configures the Default hierarchy from a file (see Hierarchy.SetupFile).
+func SetupFile(filename string) error {
+	return Default.SetupFile(filename)
 }
 
-// Automatically configures the logging hierarchy by loading the INI file specified by the GO_LOGGING_CONFIG environment
-// variable.
+// Automatically configures the Default hierarchy by loading the file specified by the GO_LOGGING_CONFIG environment
+// variable. Settings from other environment variables are layered over the file, as described by EnvConfig.
 func Setup() (err error) {
 	path := os.Getenv("GO_LOGGING_CONFIG")
 	if path == "" {
 		return errors.New("GO_LOGGING_CONFIG not set")
 	}
-	return SetupFile(path)
+	config, err := loadFiles(path)
+	if err != nil {
+		return
+	}
+	return SetupConfig(EnvConfig{config})
 }
 
 // Like Setup, but panics if an error occurs.
@@ -184,12 +512,16 @@ func MustSetup() {
 }
 
 // Sets up a minimal configuration that logs all messages to os.Stderr.
-func DefaultSetup() {
-	Root.Threshold = Trace
-	Root.AddOutput(StringOutputter{
+func (h *Hierarchy) DefaultSetup() {
+	h.Root.SetThreshold(Trace)
+	h.Root.AddOutput(StringOutputter{
 		Writer:    IOWriter{os.Stderr},
 		Formatter: NewBasicFormatter("[$level] $datetime - $msg"),
 	})
-	Root.configure()
-	configured = true
+	h.invalidate()
+}
+
+// Sets up a minimal configuration for the Default hierarchy (see Hierarchy.DefaultSetup).
+func DefaultSetup() {
+	Default.DefaultSetup()
 }
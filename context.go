@@ -0,0 +1,169 @@
+package logging
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+)
+
+type tracingKey struct{}
+
+// Returns a copy of the context with tracing enabled. Messages that are logged with the context (see
+// Logger.WithContext) bypass the Threshold of their Logger, so every message is logged, including Trace messages.
+// The thresholds of outputs still apply. Traced messages have their Traced field set, which formatters can include
+// (as the $traced variable of BasicFormatter, for example), so that they can be told apart from other messages.
+func WithTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tracingKey{}, true)
+}
+
+// Reports whether tracing is enabled for the context (see WithTracing). A nil context is not traced.
+func IsTraced(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	traced, _ := ctx.Value(tracingKey{}).(bool)
+	return traced
+}
+
+// Returns an http.Handler that enables tracing for the contexts of requests that have the given header set to a value
+// that allow accepts, and then serves them with the given handler. Since traced requests log every message, the header
+// must not be honoured for just anyone: allow can check the value against a secret (see TraceSecret), or decide from
+// the request itself, such as from its remote address. TracingHandler panics if allow is nil.
+func TracingHandler(header string, allow func(r *http.Request, value string) bool, handler http.Handler) http.Handler {
+	if allow == nil {
+		panic("logging: TracingHandler requires an allow function")
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value := r.Header.Get(header); value != "" && allow(r, value) {
+			r = r.WithContext(WithTracing(r.Context()))
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// Returns an allow function for TracingHandler that accepts header values that are equal to the secret. Values are
+// compared in constant time. TraceSecret panics if the secret is empty.
+func TraceSecret(secret string) func(r *http.Request, value string) bool {
+	if secret == "" {
+		panic("logging: TraceSecret requires a secret")
+	}
+	return func(r *http.Request, value string) bool {
+		return subtle.ConstantTimeCompare([]byte(value), []byte(secret)) == 1
+	}
+}
+
+// A ContextLogger logs messages with a context, which is included in each Message. It is created by
+// Logger.WithContext.
+type ContextLogger struct {
+	Logger  *Logger
+	Context context.Context
+}
+
+// Returns a ContextLogger, which logs messages to the Logger with the given context. If tracing is enabled for the
+// context (see WithTracing), every message is logged regardless of the Logger's Threshold.
+func (l *Logger) WithContext(ctx context.Context) ContextLogger {
+	return ContextLogger{l, ctx}
+}
+
+func (c ContextLogger) enabled(level Level) bool {
+	return IsTraced(c.Context) || c.Logger.enabled(level)
+}
+
+func (c ContextLogger) Log(level Level, msgparts ...interface{}) {
+	if !c.enabled(level) {
+		return
+	}
+	c.Logger.log(c.Context, level, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Logf(level Level, format string, args ...interface{}) {
+	if !c.enabled(level) {
+		return
+	}
+	c.Logger.log(c.Context, level, fmt.Sprintf(format, args...), args, 2)
+}
+
+func (c ContextLogger) Fatal(msgparts ...interface{}) {
+	if !c.enabled(Fatal) {
+		return
+	}
+	c.Logger.log(c.Context, Fatal, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Fatalf(format string, args ...interface{}) {
+	if !c.enabled(Fatal) {
+		return
+	}
+	c.Logger.log(c.Context, Fatal, fmt.Sprintf(format, args...), args, 2)
+}
+func (c ContextLogger) Error(msgparts ...interface{}) {
+	if !c.enabled(Error) {
+		return
+	}
+	c.Logger.log(c.Context, Error, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Errorf(format string, args ...interface{}) {
+	if !c.enabled(Error) {
+		return
+	}
+	c.Logger.log(c.Context, Error, fmt.Sprintf(format, args...), args, 2)
+}
+func (c ContextLogger) Warn(msgparts ...interface{}) {
+	if !c.enabled(Warn) {
+		return
+	}
+	c.Logger.log(c.Context, Warn, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Warnf(format string, args ...interface{}) {
+	if !c.enabled(Warn) {
+		return
+	}
+	c.Logger.log(c.Context, Warn, fmt.Sprintf(format, args...), args, 2)
+}
+func (c ContextLogger) Notice(msgparts ...interface{}) {
+	if !c.enabled(Notice) {
+		return
+	}
+	c.Logger.log(c.Context, Notice, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Noticef(format string, args ...interface{}) {
+	if !c.enabled(Notice) {
+		return
+	}
+	c.Logger.log(c.Context, Notice, fmt.Sprintf(format, args...), args, 2)
+}
+func (c ContextLogger) Info(msgparts ...interface{}) {
+	if !c.enabled(Info) {
+		return
+	}
+	c.Logger.log(c.Context, Info, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Infof(format string, args ...interface{}) {
+	if !c.enabled(Info) {
+		return
+	}
+	c.Logger.log(c.Context, Info, fmt.Sprintf(format, args...), args, 2)
+}
+func (c ContextLogger) Debug(msgparts ...interface{}) {
+	if !c.enabled(Debug) {
+		return
+	}
+	c.Logger.log(c.Context, Debug, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Debugf(format string, args ...interface{}) {
+	if !c.enabled(Debug) {
+		return
+	}
+	c.Logger.log(c.Context, Debug, fmt.Sprintf(format, args...), args, 2)
+}
+func (c ContextLogger) Trace(msgparts ...interface{}) {
+	if !c.enabled(Trace) {
+		return
+	}
+	c.Logger.log(c.Context, Trace, fmt.Sprint(msgparts...), msgparts, 2)
+}
+func (c ContextLogger) Tracef(format string, args ...interface{}) {
+	if !c.enabled(Trace) {
+		return
+	}
+	c.Logger.log(c.Context, Trace, fmt.Sprintf(format, args...), args, 2)
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTracedContext(t *testing.T) {
+	var msgs msgSlice
+	h := NewHierarchy()
+	h.Root.SetThreshold(Warn)
+	h.Root.AddOutput(&msgs)
+	logger := h.Get("a")
+
+	ctx := context.Background()
+	logger.WithContext(ctx).Debug("dropped")
+	logger.WithContext(ctx).Warn("plain")
+	traced := WithTracing(ctx)
+	logger.WithContext(traced).Tracef("traced %d", 1)
+	logger.Trace("dropped")
+
+	if len(msgs) != 2 || msgs[0].Msg != "plain" || msgs[1].Msg != "traced 1" {
+		t.Fatalf("unexpected messages: %v", msgs)
+	}
+	if msgs[0].Traced || !msgs[1].Traced || msgs[1].Context != traced {
+		t.Error("unexpected traced flags")
+	}
+	if !strings.HasSuffix(msgs[1].File, "context_test.go") {
+		t.Errorf("unexpected file: %s", msgs[1].File)
+	}
+
+	formatter := NewBasicFormatter("$msg $traced")
+	if out := formatter.Format(msgs[1]); out != "traced 1 traced" {
+		t.Errorf("unexpected format: %q", out)
+	}
+	if out := new(JSONFormatter).Format(msgs[1]); !strings.Contains(out, `"traced":true`) {
+		t.Errorf("traced field missing from JSON: %s", out)
+	}
+}
+
+func TestTracingHandler(t *testing.T) {
+	var traced []bool
+	handler := TracingHandler("X-Trace", TraceSecret("s3cret"), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = append(traced, IsTraced(r.Context()))
+	}))
+	req := httptest.NewRequest("GET", "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	req.Header.Set("X-Trace", "guess")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	req.Header.Set("X-Trace", "s3cret")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if len(traced) != 3 || traced[0] || traced[1] || !traced[2] {
+		t.Errorf("unexpected tracing: %v", traced)
+	}
+
+	// Requests can also be allowed by other properties
+	traced = nil
+	internal := func(r *http.Request, value string) bool {
+		return strings.HasPrefix(r.RemoteAddr, "10.")
+	}
+	handler = TracingHandler("X-Trace", internal, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = append(traced, IsTraced(r.Context()))
+	}))
+	req.Header.Set("X-Trace", "1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	req.RemoteAddr = "10.0.0.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if len(traced) != 2 || traced[0] || !traced[1] {
+		t.Errorf("unexpected tracing by address: %v", traced)
+	}
+}
@@ -0,0 +1,271 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// An OptionsDescriber can describe itself with the configuration options that would recreate it. Outputters,
+// Formatters and StringWriters may implement OptionsDescriber, so that DumpConfig can write them.
+type OptionsDescriber interface {
+	DescribeOptions() map[string]string
+}
+
+// Returns the options that describe a value, or nil if it cannot be described.
+func describe(value interface{}) map[string]string {
+	if describer, ok := value.(OptionsDescriber); ok {
+		return describer.DescribeOptions()
+	}
+	return nil
+}
+
+// Implements OptionsDescriber.
+func (b *BasicFormatter) DescribeOptions() map[string]string {
+	return map[string]string{"format": b.source}
+}
+
+// Implements OptionsDescriber.
+func (j *JSONFormatter) DescribeOptions() map[string]string {
+	options := map[string]string{"formatter": "json"}
+	if j.TimeLayout != "" {
+		options["timeformat"] = j.TimeLayout
+	}
+	return options
+}
+
+// Implements OptionsDescriber.
+func (l *LogfmtFormatter) DescribeOptions() map[string]string {
+	options := map[string]string{"formatter": "logfmt"}
+	if l.TimeLayout != "" {
+		options["timeformat"] = l.TimeLayout
+	}
+	if l.Keys != nil {
+		options["keys"] = strings.Join(l.Keys, ", ")
+	}
+	return options
+}
+
+// Implements OptionsDescriber. Only standard output, standard error, file descriptors opened by the "console" plugin and
+// files can be described.
+func (w IOWriter) DescribeOptions() map[string]string {
+	file, ok := w.Writer.(*os.File)
+	if !ok {
+		return nil
+	}
+	switch name := file.Name(); {
+	case file == os.Stdout || name == os.Stdout.Name():
+		return map[string]string{"type": "console", "stream": "stdout"}
+	case file == os.Stderr || name == os.Stderr.Name():
+		return map[string]string{"type": "console", "stream": "stderr"}
+	case strings.HasPrefix(name, consoleFilePrefix):
+		return map[string]string{"type": "console", "stream": name[len(consoleFilePrefix):]}
+	default:
+		return map[string]string{"type": "file", "file": name}
+	}
+}
+
+// Implements OptionsDescriber. The StringOutputter can only be described if both its StringWriter and Formatter can be.
+func (s StringOutputter) DescribeOptions() map[string]string {
+	writer, formatter := describe(s.Writer), describe(s.Formatter)
+	if writer == nil || formatter == nil {
+		return nil
+	}
+	for key, value := range formatter {
+		writer[key] = value
+	}
+	return writer
+}
+
+// Implements OptionsDescriber.
+func (t ThresholdOutputter) DescribeOptions() map[string]string {
+	options := describe(t.Outputter)
+	if options != nil {
+		options["threshold"] = t.Threshold.String()
+	}
+	return options
+}
+
+// Implements OptionsDescriber.
+func (s StackOutputter) DescribeOptions() map[string]string {
+	options := describe(s.Outputter)
+	if options != nil {
+		options["stacktrace"] = s.StackTrace.String()
+	}
+	return options
+}
+
+// Implements OptionsDescriber.
+func (n NamedOutputter) DescribeOptions() map[string]string {
+	return describe(n.Outputter)
+}
+
+// Declares the options of a plugin whose values are secret, such as passwords. DumpConfig writes their values as
+// "<redacted>".
+func (h *Hierarchy) RegisterSecretOptions(name string, options ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.secretOptions[name] = options
+}
+
+// Declares the secret options of a plugin of the Default hierarchy (see Hierarchy.RegisterSecretOptions).
+func RegisterSecretOptions(name string, options ...string) {
+	Default.RegisterSecretOptions(name, options...)
+}
+
+type dumper struct {
+	loggers   []string
+	sections  map[string]map[string]string
+	selectors map[string]loggerConfig
+	skipped   []string
+	unnamed   int
+}
+
+// Returns the name of the section describing an output, adding the section if needed. Outputs that were attached with
+// a threshold of their own are returned in the form "name@LEVEL".
+func (d *dumper) outputSection(output Outputter) (string, bool) {
+	if thresh, ok := output.(ThresholdOutputter); ok {
+		if named, ok := thresh.Outputter.(NamedOutputter); ok && d.sections[named.Name] != nil {
+			return named.Name + "@" + thresh.Threshold.String(), true
+		}
+	}
+	name := ""
+	if named, ok := output.(NamedOutputter); ok {
+		name = named.Name
+		if d.sections[name] != nil {
+			return name, true
+		}
+	}
+	options := describe(output)
+	if options == nil {
+		d.skipped = append(d.skipped, fmt.Sprintf("%s (%T)", outputName(output), output))
+		return "", false
+	}
+	// Generated names skip the names of configured sections
+	for name == "" || d.sections[name] != nil {
+		d.unnamed++
+		name = fmt.Sprintf("output%d", d.unnamed)
+	}
+	d.sections[name] = options
+	return name, true
+}
+
+// Adds the settings of a logger, if they differ from what it would inherit, and then adds its children.
+func (d *dumper) addLogger(l, parent *Logger, threshold, stackTrace Level) {
+	var parts []string
+	for _, output := range l.Outputs() {
+		if section, ok := d.outputSection(output); ok {
+			parts = append(parts, section)
+		}
+	}
+	if l.NoPropagate() {
+		parts = append(parts, "nopropagate")
+	}
+	inherited := threshold
+	if own := l.Threshold(); own != Undefined {
+		threshold = own
+	}
+	if l.StackTrace != Undefined && l.StackTrace != stackTrace {
+		stackTrace = l.StackTrace
+		parts = append(parts, "stacktrace="+stackTrace.String())
+	}
+	if l.panicLevel != Undefined {
+		parts = append(parts, "paniclevel="+l.panicLevel.String())
+	}
+	if l.onPanic != PanicInherit {
+		parts = append(parts, "onpanic="+l.onPanic.String())
+	}
+	// Loggers that were configured by a selector are covered by the selector's own entry, unless they have been changed
+	// since
+	selected := l.selector != "" && !changedSinceSelected(l, d.selectors[l.selector])
+	if !selected && threshold != Undefined && (parent == nil || len(parts) > 0 || threshold != inherited) {
+		d.loggers = append(d.loggers, l.Name+" = "+strings.Join(append([]string{threshold.String()}, parts...), ", "))
+	}
+
+	for _, child := range l.sortedChildren() {
+		d.addLogger(child, l, threshold, stackTrace)
+	}
+}
+
+// Returns a copy of a section's options with the values of its secret options replaced, or the options themselves if it
+// has none.
+func redact(options map[string]string, secrets []string) map[string]string {
+	var redacted map[string]string
+	for _, key := range secrets {
+		if _, ok := options[key]; !ok {
+			continue
+		}
+		if redacted == nil {
+			redacted = make(map[string]string, len(options))
+			for key, value := range options {
+				redacted[key] = value
+			}
+		}
+		redacted[key] = "<redacted>"
+	}
+	if redacted == nil {
+		return options
+	}
+	return redacted
+}
+
+// Reports whether a logger that was configured by a selector has been changed since, so that it needs an entry of its
+// own.
+func changedSinceSelected(l *Logger, lc loggerConfig) bool {
+	return l.Threshold() != lc.Threshold || l.StackTrace != lc.StackTrace || l.NoPropagate() != lc.NoPropagate ||
+		l.panicLevel != lc.PanicLevel || l.onPanic != lc.OnPanic || len(l.attachments()) != len(lc.Outputs)
+}
+
+// Writes the configuration of the logger hierarchy that is currently in effect to w, as an INI document that can be
+// loaded by SetupReader. Loggers are only included if their settings differ from what they would inherit. Outputs
+// that were created from configuration are written with their original options, and other outputs are written if they
+// implement OptionsDescriber. Outputs that cannot be described are listed in a comment. The values of secret options
+// (see RegisterSecretOptions) are written as "<redacted>", so a dump with secret options does not round-trip.
+func (h *Hierarchy) DumpConfig(w io.Writer) error {
+	d := &dumper{sections: make(map[string]map[string]string), selectors: make(map[string]loggerConfig)}
+	h.lock.Lock()
+	for name, options := range h.configuredSections {
+		d.sections[name] = options
+	}
+	for _, selector := range h.selectors {
+		d.selectors[selector.key] = selector.config
+	}
+	d.addLogger(h.Root, nil, Undefined, Undefined)
+	for _, selector := range h.selectors {
+		d.loggers = append(d.loggers, selector.key+" = "+selector.config.String())
+	}
+	for name, options := range d.sections {
+		d.sections[name] = redact(options, h.secretOptions[options["type"]])
+	}
+	h.lock.Unlock()
+
+	out := bufio.NewWriter(w)
+	for _, skipped := range d.skipped {
+		fmt.Fprintf(out, "; output cannot be described: %s\n", skipped)
+	}
+	fmt.Fprintln(out, "[loggers]")
+	for _, logger := range d.loggers {
+		fmt.Fprintln(out, logger)
+	}
+	names := make([]string, 0, len(d.sections))
+	for name := range d.sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "\n[%s]\n", name)
+		options := d.sections[name]
+		for _, key := range sortedStrings(options) {
+			fmt.Fprintf(out, "%s = %s\n", key, options[key])
+		}
+	}
+	return out.Flush()
+}
+
+// Writes the configuration of the Default hierarchy to w (see Hierarchy.DumpConfig).
+func DumpConfig(w io.Writer) error {
+	return Default.DumpConfig(w)
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDumpConfig(t *testing.T) {
+	defer resetLoggers()
+	err := SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, console
+  a.b = DEBUG, both, nopropagate
+  a.b.c = DEBUG, stacktrace=ERROR
+
+  [console]
+  type = console
+  stream = stderr
+  format = [$level] $msg
+
+  [both]
+  type = tee
+  outputs = console, json
+
+  [json]
+  type = console
+  stream = stdout
+  formatter = json
+  threshold = WARN
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Get("x.y").SetThreshold(Error)
+	Get("x.y").AddOutput(StringOutputter{
+		Writer:    IOWriter{os.Stdout},
+		Formatter: NewBasicFormatter("$msg"),
+	})
+
+	var dump bytes.Buffer
+	if err := DumpConfig(&dump); err != nil {
+		t.Fatal(err)
+	}
+	expect := `[loggers]
+root = INFO, console
+a.b = DEBUG, both, nopropagate
+a.b.c = DEBUG, stacktrace=ERROR
+x.y = ERROR, output1
+
+[both]
+outputs = console, json
+type = tee
+
+[console]
+format = [$level] $msg
+stream = stderr
+type = console
+
+[json]
+formatter = json
+stream = stdout
+threshold = WARN
+type = console
+
+[output1]
+format = $msg
+stream = stdout
+type = console
+`
+	if dump.String() != expect {
+		t.Fatalf("unexpected dump:\n%s", dump.String())
+	}
+
+	// The dump should round-trip
+	if err := SetupReader(strings.NewReader(expect)); err != nil {
+		t.Fatal(err)
+	}
+	dump.Reset()
+	DumpConfig(&dump)
+	if dump.String() != expect {
+		t.Errorf("dump did not round-trip:\n%s", dump.String())
+	}
+}
+
+func TestDumpConfigChanges(t *testing.T) {
+	h := NewHierarchy()
+	var logs msgSlice
+	h.RegisterOutputPlugin("secretive", &logs)
+	h.RegisterSecretOptions("secretive", "password")
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, output1
+  worker.* = WARN, output1
+
+  [output1]
+  type = secretive
+  username = admin
+  password = hunter2
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Get("worker.a").SetThreshold(Debug)
+	h.Get("worker.b")
+	h.Get("other").AddOutput(StringOutputter{
+		Writer:    IOWriter{os.Stderr},
+		Formatter: NewBasicFormatter("$msg"),
+	})
+
+	var dump bytes.Buffer
+	if err := h.DumpConfig(&dump); err != nil {
+		t.Fatal(err)
+	}
+	expect := `[loggers]
+root = INFO, output1
+other = INFO, output2
+worker.a = DEBUG, output1
+worker.* = WARN, output1
+
+[output1]
+password = <redacted>
+type = secretive
+username = admin
+
+[output2]
+format = $msg
+stream = stderr
+type = console
+`
+	if dump.String() != expect {
+		t.Errorf("unexpected dump:\n%s", dump.String())
+	}
+}
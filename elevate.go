@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Temporarily lowers the threshold of the named logger ("root" for the root logger) and all of its descendants to the
+// given level, and restores it when the duration has passed. A duration of zero or less lasts until the returned
+// cancel function is called, which can also be used to end an elevation early.
+//
+// Elevations only ever make loggers more verbose, and they are kept separately from the loggers' own thresholds, so a
+// new configuration does not end them. When elevations overlap, the most verbose one that is still active applies, so
+// ending an elevation never undoes another one:
+//
+//	cancel := logging.Elevate("payments", logging.Trace, 10*time.Minute)
+//	defer cancel()
+func (h *Hierarchy) Elevate(name string, level Level, duration time.Duration) (cancel func()) {
+	logger := h.Root
+	if name != "root" {
+		logger = h.Get(name)
+	}
+	h.lock.Lock()
+	logger.elevations = append(logger.elevations, level)
+	h.lock.Unlock()
+	atomic.AddInt32(&h.elevated, 1)
+	h.invalidate()
+
+	var once sync.Once
+	end := func() {
+		once.Do(func() {
+			h.lock.Lock()
+			for i, elevation := range logger.elevations {
+				if elevation == level {
+					logger.elevations = append(logger.elevations[:i:i], logger.elevations[i+1:]...)
+					break
+				}
+			}
+			h.lock.Unlock()
+			atomic.AddInt32(&h.elevated, -1)
+			h.invalidate()
+		})
+	}
+	if duration <= 0 {
+		return end
+	}
+	timer := time.AfterFunc(duration, end)
+	return func() {
+		timer.Stop()
+		end()
+	}
+}
+
+// Temporarily lowers the threshold of a logger in the Default hierarchy (see Hierarchy.Elevate).
+func Elevate(name string, level Level, duration time.Duration) (cancel func()) {
+	return Default.Elevate(name, level, duration)
+}
+
+// Returns the most verbose elevation of a logger and its ancestors, or Undefined if there is none.
+func (h *Hierarchy) elevation(l *Logger) Level {
+	if atomic.LoadInt32(&h.elevated) == 0 {
+		return Undefined
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	result := Undefined
+	for logger := l; logger != nil; logger = logger.parent {
+		for _, level := range logger.elevations {
+			if result == Undefined || level < result {
+				result = level
+			}
+		}
+	}
+	return result
+}
+
+// Toggles an elevation of the root logger to Debug, which lasts until ToggleDebug is called again. Reports whether the
+// root logger is now elevated.
+func (h *Hierarchy) ToggleDebug() bool {
+	h.lock.Lock()
+	cancel := h.debugToggle
+	h.debugToggle = nil
+	h.lock.Unlock()
+	if cancel != nil {
+		cancel()
+		return false
+	}
+	cancel = h.Elevate("root", Debug, 0)
+	h.lock.Lock()
+	h.debugToggle = cancel
+	h.lock.Unlock()
+	return true
+}
+
+// Toggles an elevation of the Default hierarchy's root logger (see Hierarchy.ToggleDebug).
+func ToggleDebug() bool {
+	return Default.ToggleDebug()
+}
+
+// Calls ToggleDebug whenever one of the given signals is received, until the returned stop function is called. On
+// Unix systems, this is typically used with SIGUSR1:
+//
+//	stop := logging.ToggleDebugOnSignal(syscall.SIGUSR1)
+//	defer stop()
+func (h *Hierarchy) ToggleDebugOnSignal(signals ...os.Signal) (stop func()) {
+	received := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(received, signals...)
+	go func() {
+		for {
+			select {
+			case <-received:
+				h.ToggleDebug()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(received)
+			close(done)
+		})
+	}
+}
+
+// Toggles an elevation of the Default hierarchy's root logger on a signal (see Hierarchy.ToggleDebugOnSignal).
+func ToggleDebugOnSignal(signals ...os.Signal) (stop func()) {
+	return Default.ToggleDebugOnSignal(signals...)
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"testing"
+	"time"
+)
+
+func TestElevate(t *testing.T) {
+	h := NewHierarchy()
+	h.Root.SetThreshold(Info)
+	payments, db := h.Get("payments"), h.Get("payments.db")
+	db.SetThreshold(Warn)
+	other := h.Get("other")
+
+	outer := h.Elevate("payments", Debug, 0)
+	if payments.EffectiveThreshold() != Debug || db.EffectiveThreshold() != Debug || other.EffectiveThreshold() != Info {
+		t.Error("elevation did not apply to the subtree")
+	}
+
+	// A nested elevation to a more verbose level applies until it ends, and then the outer elevation applies again
+	inner := h.Elevate("payments.db", Trace, 0)
+	if db.EffectiveThreshold() != Trace || payments.EffectiveThreshold() != Debug {
+		t.Error("nested elevation did not apply")
+	}
+	inner()
+	inner()
+	if db.EffectiveThreshold() != Debug {
+		t.Errorf("unexpected threshold after nested elevation ended: %v", db.EffectiveThreshold())
+	}
+
+	// Elevations survive reconfiguration, and never raise a threshold
+	h.DefaultSetup()
+	quieter := h.Elevate("payments", Error, 0)
+	if db.EffectiveThreshold() != Debug {
+		t.Errorf("unexpected threshold with a quieter elevation: %v", db.EffectiveThreshold())
+	}
+	quieter()
+	outer()
+	if payments.EffectiveThreshold() != Trace || db.EffectiveThreshold() != Warn {
+		t.Errorf("thresholds were not restored: %v, %v", payments.EffectiveThreshold(), db.EffectiveThreshold())
+	}
+
+	h.Elevate("payments.db", Debug, 10*time.Millisecond)
+	if db.EffectiveThreshold() != Debug {
+		t.Error("timed elevation did not apply")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for db.EffectiveThreshold() != Warn {
+		if time.Now().After(deadline) {
+			t.Fatal("timed elevation was not reverted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	h := NewHierarchy()
+	h.Root.SetThreshold(Warn)
+	if !h.ToggleDebug() || h.Get("a").EffectiveThreshold() != Debug {
+		t.Error("root was not elevated")
+	}
+	if h.ToggleDebug() || h.Get("a").EffectiveThreshold() != Warn {
+		t.Error("root elevation was not ended")
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestToggleDebugOnSignal(t *testing.T) {
+	h := NewHierarchy()
+	h.Root.SetThreshold(Warn)
+
+	stop := h.ToggleDebugOnSignal(syscall.SIGUSR1)
+	defer stop()
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for h.Root.EffectiveThreshold() != Debug {
+		if time.Now().After(deadline) {
+			t.Fatal("signal did not elevate root")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
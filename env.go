@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// EnvConfig wraps a Config, and layers settings from environment variables over it. In order of precedence:
+//
+//	GO_LOGGING_LEVEL_<logger>  Overrides only the level of a logger. Double underscores in the logger name are replaced
+//	                           with dots, so GO_LOGGING_LEVEL_db__pool=DEBUG sets the level of the "db.pool" logger,
+//	                           while GO_LOGGING_LEVEL_db_pool=DEBUG sets the level of the "db_pool" logger.
+//	GO_LOGGING_ROOT            Replaces the settings of the root logger, eg. GO_LOGGING_ROOT=WARN,console.
+//	the wrapped Config         Settings from the configuration file.
+//
+// In addition, references to environment variables in the form ${NAME} or ${NAME:-default} are replaced in logger
+// settings and output options. The default is used if the variable is unset or empty.
+type EnvConfig struct {
+	Config Config
+}
+
+// Implements Config.
+func (e EnvConfig) LoggerSettings() map[string]string {
+	settings := make(map[string]string)
+	for name, setting := range e.Config.LoggerSettings() {
+		settings[name] = Interpolate(setting)
+	}
+	if root := os.Getenv("GO_LOGGING_ROOT"); root != "" {
+		settings["root"] = root
+	}
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, "GO_LOGGING_LEVEL_") {
+			continue
+		}
+		parts := strings.SplitN(env[len("GO_LOGGING_LEVEL_"):], "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		name := strings.Replace(parts[0], "__", ".", -1)
+		setting := strings.SplitN(settings[name], ",", 2)
+		setting[0] = parts[1]
+		settings[name] = strings.Join(setting, ",")
+	}
+	return settings
+}
+
+// Implements Config.
+func (e EnvConfig) Plugins() []PluginConfig {
+	plugins := e.Config.Plugins()
+	result := make([]PluginConfig, len(plugins))
+	for i, plugin := range plugins {
+		options := make(map[string]string, len(plugin.Options))
+		for key, value := range plugin.Options {
+			options[key] = Interpolate(value)
+		}
+		result[i] = PluginConfig{
+			Name:    plugin.Name,
+			Options: options,
+		}
+	}
+	return result
+}
+
+var envRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Replaces references to environment variables in the form ${NAME} or ${NAME:-default} with their values. The default
+// is used if the variable is unset or empty.
+func Interpolate(str string) string {
+	return envRegex.ReplaceAllStringFunc(str, func(ref string) string {
+		match := envRegex.FindStringSubmatch(ref)
+		if value := os.Getenv(match[1]); value != "" {
+			return value
+		}
+		return match[3]
+	})
+}
@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"github.com/vaughan0/go-ini"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnvConfig(t *testing.T) {
+	file, err := ini.Load(strings.NewReader(`
+  [loggers]
+  root = INFO, console
+  db.pool = INFO, console, nopropagate
+  db_pool = INFO, console
+
+  [console]
+  type = console
+  stream = ${LOG_STREAM:-stderr}
+  format = ${LOG_PREFIX}$msg
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GO_LOGGING_ROOT", "WARN, console")
+	t.Setenv("GO_LOGGING_LEVEL_db__pool", "DEBUG")
+	t.Setenv("GO_LOGGING_LEVEL_db_pool", "WARN")
+	t.Setenv("GO_LOGGING_LEVEL_cache", "TRACE")
+	t.Setenv("LOG_STREAM", "")
+	t.Setenv("LOG_PREFIX", "app: ")
+	config := EnvConfig{IniConfig(file)}
+
+	expectLoggers := map[string]string{
+		"root":    "WARN, console",
+		"db.pool": "DEBUG, console, nopropagate",
+		"db_pool": "WARN, console",
+		"cache":   "TRACE",
+	}
+	if !reflect.DeepEqual(config.LoggerSettings(), expectLoggers) {
+		t.Errorf("unexpected logger settings: %v", config.LoggerSettings())
+	}
+	expectOptions := map[string]string{"type": "console", "stream": "stderr", "format": "app: $msg"}
+	if options := config.Plugins()[0].Options; !reflect.DeepEqual(options, expectOptions) {
+		t.Errorf("unexpected options: %v", options)
+	}
+}
@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// NamedOutputter wraps an Outputter with a name, which is used to identify the Outputter when it reports an error.
+// Outputters created from configuration sections are named after their sections. The latency of named outputs is
+// recorded (see Stats).
+type NamedOutputter struct {
+	Name      string
+	Outputter Outputter
+	// The latency histogram of the output, which is looked up by name when the NamedOutputter was created without
+	// NewNamedOutputter.
+	latency *Histogram
+}
+
+// Creates a NamedOutputter, resolving its latency histogram in advance. Outputs that report errors from a goroutine of
+// their own, such as BatchOutputter, are told the name so that they report them under it too.
+func NewNamedOutputter(name string, o Outputter) NamedOutputter {
+	if namer, ok := baseOutputter(o).(interface{ setName(name string) }); ok {
+		namer.setName(name)
+	}
+	return NamedOutputter{Name: name, Outputter: o, latency: latencyHistogram(name)}
+}
+
+// Implements Outputter.
+func (n NamedOutputter) Output(msg *Message) {
+	n.OutputErr(msg)
+}
+
+// Implements StackWanter.
+func (n NamedOutputter) WantsStack() bool {
+	return wantsStack(n.Outputter)
+}
+
+// Implements ErrorOutputter.
+func (n NamedOutputter) OutputErr(msg *Message) error {
+	return OutputErr(n.Outputter, msg)
+}
+
+var errorLock sync.Mutex
+var errorHandler func(output string, err error)
+var errorCounts = make(map[string]uint64)
+var emergencyFallback bool
+
+// Sets a function to be called whenever one of a Logger's outputs reports an error. The output parameter is the name of
+// the output (see NamedOutputter), or its type if it has no name. Passing nil removes the handler.
+func OnError(handler func(output string, err error)) {
+	errorLock.Lock()
+	defer errorLock.Unlock()
+	errorHandler = handler
+}
+
+// Enables or disables the emergency fallback. When enabled, messages that could not be output are written to os.Stderr
+// along with the error that occurred.
+func SetEmergencyFallback(enabled bool) {
+	errorLock.Lock()
+	defer errorLock.Unlock()
+	emergencyFallback = enabled
+}
+
+// Returns the number of failed writes for each output, keyed by the same names that are passed to the OnError handler.
+func OutputErrors() map[string]uint64 {
+	errorLock.Lock()
+	defer errorLock.Unlock()
+	counts := make(map[string]uint64, len(errorCounts))
+	for name, count := range errorCounts {
+		counts[name] = count
+	}
+	return counts
+}
+
+func outputName(o Outputter) string {
+	if thresh, ok := o.(ThresholdOutputter); ok {
+		if named, ok := thresh.Outputter.(NamedOutputter); ok {
+			return named.Name
+		}
+	}
+	if named, ok := o.(NamedOutputter); ok {
+		return named.Name
+	}
+	return fmt.Sprintf("%T", o)
+}
+
+// Records an error reported by an output, and passes it on to the error handler and the emergency fallback.
+func reportError(output Outputter, msg *Message, err error) {
+	reportErrors(output, []*Message{msg}, err)
+}
+
+// Like reportError, but for a single error that affected several messages, such as a batch that could not be sent.
+// The error is counted once, and every message is written to the emergency fallback.
+func reportErrors(output Outputter, msgs []*Message, err error) {
+	name := outputName(output)
+	errorLock.Lock()
+	errorCounts[name]++
+	handler, fallback := errorHandler, emergencyFallback
+	errorLock.Unlock()
+
+	for _, sink := range getSinks() {
+		sink.OutputError(name)
+	}
+	if handler != nil {
+		handler(name, err)
+	}
+	if !fallback {
+		return
+	}
+	for _, msg := range msgs {
+		fmt.Fprintf(os.Stderr, "[%s] %s - %s (output %s failed: %s)\n", msg.Level, msg.Logger.Name, msg.Msg, name, err)
+	}
+}
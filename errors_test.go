@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"errors"
+	"testing"
+)
+
+type brokenWriter struct{}
+
+func (brokenWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestOutputErrors(t *testing.T) {
+	resetLoggers()
+	defer resetLoggers()
+	var reported []string
+	OnError(func(output string, err error) {
+		reported = append(reported, output+": "+err.Error())
+	})
+	defer OnError(nil)
+
+	logger := Get("errors")
+	logger.SetThreshold(Info)
+	logger.AddOutput(NamedOutputter{Name: "broken", Outputter: StringOutputter{
+		Writer:    IOWriter{brokenWriter{}},
+		Formatter: NewBasicFormatter("$msg"),
+	}})
+	before := OutputErrors()["broken"]
+	logger.Info("lost")
+
+	if len(reported) != 1 || reported[0] != "broken: disk full" {
+		t.Fatalf("unexpected reported errors: %v", reported)
+	}
+	if count := OutputErrors()["broken"]; count != before+1 {
+		t.Fatalf("expected %d failed writes, got %d", before+1, count)
+	}
+}
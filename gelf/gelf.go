@@ -0,0 +1,389 @@
+// Package gelf provides a GELF plugin for go-logging, which sends messages to Graylog over UDP or TCP.
+//
+// Importing the package registers the "gelf" output type:
+//
+//	[graylog]
+//	type = gelf
+//	address = graylog:12201
+//	protocol = udp
+//	compression = gzip
+//	fields = app=payments, env=prod
+//
+// Messages are encoded as GELF 1.1. Over UDP, they are compressed and split into chunks if they are too large for a
+// single datagram. Over TCP, they are framed with null bytes, and cannot be compressed. TCP outputs send messages in
+// batches from a background goroutine (see logging.BatchOutputter), so that a slow or unavailable server does not
+// block logging, and they accept the options read by logging.ParseBatchOptions.
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The compression methods supported over UDP.
+const (
+	CompressGzip = "gzip"
+	CompressZlib = "zlib"
+	CompressNone = "none"
+)
+
+// The chunk size used when none is configured, which fits in a datagram on most networks.
+const DefaultChunkSize = 1420
+
+// The maximum number of chunks in a message, as defined by the GELF specification.
+const maxChunks = 128
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// Returned when a message is too large to be sent in the maximum number of chunks.
+var ErrTooLarge = errors.New("gelf message is too large")
+
+// The time allowed for each write over TCP when none is configured.
+const DefaultWriteTimeout = 10 * time.Second
+
+// The names that additional fields can have, as defined by the GELF specification (without the leading underscore).
+var fieldName = regexp.MustCompile(`^[\w\.\-]+$`)
+
+// Returns the syslog severity for a level, which is used as the GELF level.
+func Severity(level logging.Level) int {
+	switch level {
+	case logging.Fatal:
+		return 2
+	case logging.Error:
+		return 3
+	case logging.Warn:
+		return 4
+	case logging.Notice:
+		return 5
+	case logging.Info:
+		return 6
+	}
+	return 7
+}
+
+// Writer sends encoded GELF messages to a server. It is safe for concurrent use.
+type Writer struct {
+	// Either "udp" or "tcp".
+	Network string
+	// The address of the server, in the form "host:port".
+	Address string
+	// The compression used over UDP: CompressGzip (the default), CompressZlib or CompressNone.
+	Compression string
+	// The maximum size of a UDP datagram. Defaults to DefaultChunkSize.
+	ChunkSize int
+	// The time allowed for each write over TCP, after which the connection is considered to have failed. Defaults to
+	// DefaultWriteTimeout.
+	WriteTimeout time.Duration
+
+	// Guards conn. Over TCP, it is held while writing, so that messages are not interleaved.
+	lock sync.Mutex
+	conn net.Conn
+}
+
+// Creates a Writer and connects it to a server.
+func Dial(network, address string) (*Writer, error) {
+	w := &Writer{Network: network, Address: address}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) connect() (err error) {
+	w.conn, err = net.DialTimeout(w.Network, w.Address, 10*time.Second)
+	return
+}
+
+// Sends an encoded message.
+func (w *Writer) WriteMessage(data []byte) error {
+	return w.WriteMessages([][]byte{data})
+}
+
+// Sends several encoded messages. Over TCP, they are written to the connection together.
+func (w *Writer) WriteMessages(messages [][]byte) error {
+	if w.Network == "tcp" {
+		var frames []byte
+		for _, data := range messages {
+			frames = append(append(frames, data...), 0)
+		}
+		w.lock.Lock()
+		defer w.lock.Unlock()
+		return w.writeTCP(frames)
+	}
+	for _, data := range messages {
+		if err := w.writeUDP(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes null-terminated messages, reconnecting once if the connection has failed. After a reconnection, only the
+// messages that were not completely written are sent again. The lock must be held.
+func (w *Writer) writeTCP(frames []byte) error {
+	timeout := w.WriteTimeout
+	if timeout <= 0 {
+		timeout = DefaultWriteTimeout
+	}
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			if err := w.connect(); err != nil {
+				return err
+			}
+		}
+		w.conn.SetWriteDeadline(time.Now().Add(timeout))
+		n, err := w.conn.Write(frames)
+		if err == nil || attempt > 0 {
+			return err
+		}
+		if i := bytes.LastIndexByte(frames[:n], 0); i >= 0 {
+			frames = frames[i+1:]
+		}
+		w.conn.Close()
+		w.conn = nil
+		if len(frames) == 0 {
+			return nil
+		}
+	}
+}
+
+// Returns the Writer's connection, and connects it first if needed.
+func (w *Writer) connection() (net.Conn, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.conn == nil {
+		if err := w.connect(); err != nil {
+			return nil, err
+		}
+	}
+	return w.conn, nil
+}
+
+// Compresses a message and writes it in one or more datagrams. The lock is only held to get the connection, since
+// datagrams can be written concurrently.
+func (w *Writer) writeUDP(data []byte) error {
+	var buf bytes.Buffer
+	switch w.Compression {
+	case "", CompressGzip:
+		writer := gzip.NewWriter(&buf)
+		writer.Write(data)
+		writer.Close()
+		data = buf.Bytes()
+	case CompressZlib:
+		writer := zlib.NewWriter(&buf)
+		writer.Write(data)
+		writer.Close()
+		data = buf.Bytes()
+	case CompressNone:
+	default:
+		return errors.New("unknown gelf compression: " + w.Compression)
+	}
+	conn, err := w.connection()
+	if err != nil {
+		return err
+	}
+
+	chunkSize := w.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if len(data) <= chunkSize {
+		_, err = conn.Write(data)
+		return err
+	}
+
+	// Each chunk has a 12 byte header: the magic bytes, a message ID, the sequence number and the number of chunks
+	payload := chunkSize - 12
+	count := (len(data) + payload - 1) / payload
+	if count > maxChunks {
+		return ErrTooLarge
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * payload
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := make([]byte, 0, 12+end-i*payload)
+		chunk = append(chunk, chunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data[i*payload:end]...)
+		if _, err := conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Closes the Writer's connection.
+func (w *Writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// The name of this host, which is resolved once.
+var hostname, _ = os.Hostname()
+
+// Outputter implements logging.Outputter by sending messages to a GELF server. It also implements
+// logging.BatchSender, so that it can be used with a logging.BatchOutputter.
+type Outputter struct {
+	Writer *Writer
+	// The name of the host that sends the messages. Defaults to the result of os.Hostname.
+	Host string
+	// Additional fields to include in every message. Their names must not include the leading underscore.
+	Fields map[string]string
+}
+
+// Encodes a message as GELF 1.1. The message's stack trace, if any, is included in the full_message field, and its
+// Fields are included as additional fields, unless their names are not allowed by GELF.
+func (o *Outputter) Encode(msg *logging.Message) ([]byte, error) {
+	host := o.Host
+	if host == "" {
+		host = hostname
+	}
+	short := msg.Msg
+	if i := strings.IndexByte(short, '\n'); i >= 0 {
+		short = short[:i]
+	}
+	fields := map[string]interface{}{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": short,
+		"timestamp":     float64(msg.Time.UnixNano()) / 1e9,
+		"level":         Severity(msg.Level),
+	}
+	if msg.Stack != "" {
+		fields["full_message"] = msg.Msg + "\n" + msg.Stack
+	} else if short != msg.Msg {
+		fields["full_message"] = msg.Msg
+	}
+	for name, value := range o.Fields {
+		fields["_"+name] = value
+	}
+	for name, value := range msg.Fields {
+		if name != "id" && fieldName.MatchString(name) {
+			fields["_"+name] = value
+		}
+	}
+	if msg.Logger != nil {
+		fields["_logger"] = msg.Logger.Name
+	}
+	if msg.File != "" {
+		fields["_file"] = path.Base(msg.File)
+		fields["_line"] = msg.Line
+	}
+	if len(msg.Errors) > 0 {
+		fields["_error"] = msg.Errors[0].Error()
+	}
+	if msg.Traced {
+		fields["_traced"] = true
+	}
+	return json.Marshal(fields)
+}
+
+// Implements logging.Outputter.
+func (o *Outputter) Output(msg *logging.Message) {
+	o.OutputErr(msg)
+}
+
+// Implements logging.ErrorOutputter.
+func (o *Outputter) OutputErr(msg *logging.Message) error {
+	data, err := o.Encode(msg)
+	if err != nil {
+		return err
+	}
+	return o.Writer.WriteMessage(data)
+}
+
+// Implements logging.BatchSender. Messages that cannot be encoded are skipped.
+func (o *Outputter) SendBatch(msgs []*logging.Message) error {
+	messages := make([][]byte, 0, len(msgs))
+	for _, msg := range msgs {
+		if data, err := o.Encode(msg); err == nil {
+			messages = append(messages, data)
+		}
+	}
+	return o.Writer.WriteMessages(messages)
+}
+
+// Closes the Outputter's Writer.
+func (o *Outputter) Close() error {
+	return o.Writer.Close()
+}
+
+var gelfPlugin = logging.OutputPluginFunc(func(options map[string]string) (logging.Outputter, error) {
+	address := options["address"]
+	if address == "" {
+		return nil, errors.New("gelf address not specified")
+	}
+	network := options["protocol"]
+	if network == "" {
+		network = "udp"
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, errors.New("unknown gelf protocol: " + network)
+	}
+	w := &Writer{Network: network, Address: address, Compression: options["compression"]}
+	switch w.Compression {
+	case "", CompressGzip, CompressZlib, CompressNone:
+	default:
+		return nil, errors.New("unknown gelf compression: " + w.Compression)
+	}
+	if network == "tcp" && w.Compression != "" && w.Compression != CompressNone {
+		return nil, errors.New("gelf compression is not supported over tcp")
+	}
+	if size, ok := options["chunk_size"]; ok {
+		var err error
+		if w.ChunkSize, err = strconv.Atoi(size); err != nil || w.ChunkSize <= 12 {
+			return nil, fmt.Errorf("invalid gelf chunk_size: %s", size)
+		}
+	}
+	fields, err := logging.ParseKeyValues(options["fields"])
+	if err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if name == "id" || strings.HasPrefix(name, "_") || !fieldName.MatchString(name) {
+			return nil, errors.New("invalid gelf field name: " + name)
+		}
+	}
+
+	// Connections are made when the first message is sent, so that an unavailable server does not prevent setup
+	o := &Outputter{Writer: w, Host: options["host"], Fields: fields}
+	if network == "udp" {
+		return o, nil
+	}
+	batch, err := logging.ParseBatchOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return logging.NewBatchOutputter(o, batch), nil
+})
+
+func init() {
+	logging.RegisterOutputPlugin("gelf", gelfPlugin)
+	options := []string{"address", "protocol", "compression", "chunk_size", "host", "fields"}
+	logging.RegisterPluginOptions("gelf", append(options, logging.BatchPluginOptions...)...)
+}
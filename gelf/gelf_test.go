@@ -0,0 +1,380 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"github.com/vaughan0/go-logging"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testMessage(msg string) *logging.Message {
+	h := logging.NewHierarchy()
+	return &logging.Message{
+		Level:  logging.Warn,
+		Msg:    msg,
+		Logger: h.Get("app.db"),
+		Time:   time.Unix(1700000000, 500000000),
+		File:   "/src/app/db.go",
+		Line:   42,
+	}
+}
+
+func decodeMessage(t *testing.T, data []byte) map[string]interface{} {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("invalid message %q: %v", data, err)
+	}
+	return fields
+}
+
+func TestEncode(t *testing.T) {
+	o := &Outputter{Host: "web1", Fields: map[string]string{"env": "prod"}}
+	msg := testMessage("query failed\ndetails")
+	msg.Stack = "goroutine 1 [running]:"
+	msg.Fields = map[string]string{"request.id": "abc", "not allowed": "x"}
+	data, err := o.Encode(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := decodeMessage(t, data)
+	expected := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "web1",
+		"short_message": "query failed",
+		"full_message":  "query failed\ndetails\ngoroutine 1 [running]:",
+		"timestamp":     1700000000.5,
+		"level":         4.0,
+		"_env":          "prod",
+		"_logger":       "app.db",
+		"_file":         "db.go",
+		"_line":         42.0,
+		"_request.id":   "abc",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, fields[key])
+		}
+	}
+	if len(fields) != len(expected) {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+
+	data, _ = o.Encode(testMessage("short"))
+	if _, ok := decodeMessage(t, data)["full_message"]; ok {
+		t.Errorf("unexpected full_message: %s", data)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	levels := map[logging.Level]int{
+		logging.Fatal:  2,
+		logging.Error:  3,
+		logging.Warn:   4,
+		logging.Notice: 5,
+		logging.Info:   6,
+		logging.Debug:  7,
+		logging.Trace:  7,
+	}
+	for level, severity := range levels {
+		if s := Severity(level); s != severity {
+			t.Errorf("expected %s to map to %d, got %d", level, severity, s)
+		}
+	}
+}
+
+// Receives a datagram from conn, reassembling chunks.
+func receive(t *testing.T, conn net.PacketConn) []byte {
+	var chunks [][]byte
+	received := 0
+	buf := make([]byte, 65536)
+	for {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := append([]byte(nil), buf[:n]...)
+		if !bytes.HasPrefix(data, chunkMagic) {
+			return data
+		}
+		seq, count := int(data[10]), int(data[11])
+		if chunks == nil {
+			chunks = make([][]byte, count)
+		}
+		chunks[seq] = data[12:]
+		if received++; received == count {
+			return bytes.Join(chunks, nil)
+		}
+	}
+}
+
+func TestUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	// Random text does not compress well, so that the message is chunked
+	var random strings.Builder
+	for i, x := 0, uint32(1); i < 5000; i++ {
+		x = x*1103515245 + 12345
+		random.WriteByte(byte('a' + x>>16%26))
+	}
+	tests := []struct {
+		compression string
+		msg         string
+		decompress  func(io.Reader) (io.Reader, error)
+	}{
+		{CompressGzip, "gzipped", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{CompressZlib, "zlibbed", func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) }},
+		{CompressNone, "plain", nil},
+		{CompressGzip, random.String(), func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+	}
+	for _, test := range tests {
+		w := &Writer{Network: "udp", Address: conn.LocalAddr().String(), Compression: test.compression, ChunkSize: 1000}
+		o := &Outputter{Writer: w, Host: "test"}
+		if err := o.OutputErr(testMessage(test.msg)); err != nil {
+			t.Fatal(err)
+		}
+		data := receive(t, conn)
+		if test.decompress != nil {
+			r, err := test.decompress(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ = io.ReadAll(r)
+		}
+		if fields := decodeMessage(t, data); fields["short_message"] != test.msg {
+			t.Errorf("unexpected message with %s compression: %.40s", test.compression, fields["short_message"])
+		}
+		o.Close()
+	}
+
+	w := &Writer{Network: "udp", Address: conn.LocalAddr().String(), Compression: CompressNone, ChunkSize: 20}
+	if err := w.WriteMessage(make([]byte, 8*maxChunks+1)); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	w.Close()
+}
+
+func TestTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+	frames := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					frame, err := reader.ReadString(0)
+					if err != nil {
+						return
+					}
+					frames <- strings.TrimSuffix(frame, "\x00")
+				}
+			}()
+		}
+	}()
+
+	w := &Writer{Network: "tcp", Address: listener.Addr().String()}
+	o := &Outputter{Writer: w}
+	defer o.Close()
+	for _, msg := range []string{"one", "two"} {
+		if err := o.OutputErr(testMessage(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, expected := range []string{"one", "two"} {
+		select {
+		case frame := <-frames:
+			if fields := decodeMessage(t, []byte(frame)); fields["short_message"] != expected {
+				t.Errorf("expected %s, got %s", expected, frame)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for message")
+		}
+	}
+
+	// The plugin sends messages over TCP in batches, from a background goroutine
+	h := logging.NewHierarchy()
+	err = h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, graylog
+
+  [graylog]
+  type = gelf
+  protocol = tcp
+  address = ` + listener.Addr().String() + `
+  batch_interval = 1h
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Root.Info("three")
+	h.Root.Info("four")
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"three", "four"} {
+		select {
+		case frame := <-frames:
+			if fields := decodeMessage(t, []byte(frame)); fields["short_message"] != expected || fields["host"] != hostname {
+				t.Errorf("expected %s, got %s", expected, frame)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for batched message")
+		}
+	}
+	if err := h.SetupReader(strings.NewReader("[loggers]\nroot = INFO\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A connection that fails after writing some bytes.
+type brokenConn struct {
+	net.Conn
+	written  []byte
+	limit    int
+	deadline time.Time
+}
+
+func (b *brokenConn) Write(data []byte) (int, error) {
+	n := len(data)
+	if n > b.limit {
+		n = b.limit
+	}
+	b.written = append(b.written, data[:n]...)
+	return n, errors.New("connection reset")
+}
+
+func (b *brokenConn) SetWriteDeadline(t time.Time) error {
+	b.deadline = t
+	return nil
+}
+
+func (b *brokenConn) Close() error {
+	return nil
+}
+
+func TestTCPReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	// The first message was written completely, and the second one is sent again in full
+	broken := &brokenConn{limit: 6}
+	w := &Writer{Network: "tcp", Address: listener.Addr().String(), conn: broken}
+	if err := w.WriteMessages([][]byte{[]byte("one"), []byte("two")}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if string(broken.written) != "one\x00tw" || broken.deadline.IsZero() {
+		t.Errorf("unexpected write to the broken connection: %q, %v", broken.written, broken.deadline)
+	}
+	select {
+	case data := <-received:
+		if data != "two\x00" {
+			t.Errorf("unexpected data after reconnecting: %q", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the resent message")
+	}
+}
+
+func TestTCPUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	output, err := gelfPlugin.CreateOutputter(map[string]string{"address": address, "protocol": "tcp", "max_retries": "-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, ok := output.(*logging.BatchOutputter)
+	if !ok {
+		t.Fatalf("tcp output is not batched: %T", output)
+	}
+	defer batch.Close()
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		batch.Output(testMessage("unsent"))
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("logging blocked on an unavailable server for %v", elapsed)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	h := logging.NewHierarchy()
+	err = h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, graylog
+
+  [graylog]
+  type = gelf
+  address = ` + conn.LocalAddr().String() + `
+  compression = none
+  fields = app=test
+  host = web1
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Root.Error("failed")
+	fields := decodeMessage(t, receive(t, conn))
+	if fields["short_message"] != "failed" || fields["_app"] != "test" || fields["host"] != "web1" || fields["level"] != 3.0 {
+		t.Errorf("unexpected message: %v", fields)
+	}
+
+	invalid := []map[string]string{
+		{},
+		{"address": "localhost:12201", "protocol": "sctp"},
+		{"address": "localhost:12201", "compression": "lz4"},
+		{"address": "localhost:12201", "protocol": "tcp", "compression": "gzip"},
+		{"address": "localhost:12201", "chunk_size": "10"},
+		{"address": "localhost:12201", "fields": "_id=1"},
+		{"address": "localhost:12201", "fields": "user name=1"},
+	}
+	for _, options := range invalid {
+		if _, err := gelfPlugin.CreateOutputter(options); err == nil {
+			t.Errorf("expected an error for %v", options)
+		}
+	}
+}
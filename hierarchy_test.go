@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHierarchiesAreIsolated(t *testing.T) {
+	var first, second msgSlice
+	h1, h2 := NewHierarchy(), NewHierarchy()
+	h1.RegisterOutputPlugin("mock", &first)
+	h2.RegisterOutputPlugin("mock", &second)
+	config := `
+  [loggers]
+  root = %s, mock
+
+  [mock]
+  type = mock
+  `
+	if err := h1.SetupReader(strings.NewReader(strings.Replace(config, "%s", "INFO", 1))); err != nil {
+		t.Fatal(err)
+	}
+	if err := h2.SetupReader(strings.NewReader(strings.Replace(config, "%s", "WARN", 1))); err != nil {
+		t.Fatal(err)
+	}
+
+	h1.Get("a.b").Info("one")
+	h2.Get("a.b").Info("two")
+	h2.Get("a.b").Warn("three")
+	if len(first) != 1 || first[0].Msg != "one" {
+		t.Errorf("unexpected messages in first hierarchy: %v", first)
+	}
+	if len(second) != 1 || second[0].Msg != "three" {
+		t.Errorf("unexpected messages in second hierarchy: %v", second)
+	}
+	if h1.Get("a.b") == Get("a.b") || h1.Get("a.b") == h2.Get("a.b") {
+		t.Error("hierarchies share loggers")
+	}
+
+	// Plugins registered with one hierarchy are not available in others
+	h1.RegisterOutputPlugin("only", &first)
+	err := h2.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, only
+
+  [only]
+  type = only
+  `))
+	if err == nil {
+		t.Error("plugin was shared between hierarchies")
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	h := NewHierarchy()
+	h.Root.SetThreshold(Warn)
+	c := h.Get("a.b.c")
+	h.Get("a.d")
+	b := c.Parent()
+	if b.Name != "a.b" || b.Parent().Name != "a" || b.Parent().Parent() != h.Root || h.Root.Parent() != nil {
+		t.Errorf("unexpected ancestors: %s, %s", b.Name, b.Parent().Name)
+	}
+	if h.Get("a.b") != b {
+		t.Error("intermediate logger was not reused")
+	}
+
+	var names []string
+	h.Walk(func(logger *Logger) {
+		names = append(names, logger.Name)
+	})
+	if expected := "root a a.b a.b.c a.d"; strings.Join(names, " ") != expected {
+		t.Errorf("walked %v, expected %s", names, expected)
+	}
+	if loggers := h.Loggers(); len(loggers) != 5 || loggers[2] != b {
+		t.Errorf("unexpected loggers: %v", loggers)
+	}
+	if children := h.Get("a").Children(); len(children) != 2 || children[0] != b || children[1].Name != "a.d" {
+		t.Errorf("unexpected children: %v", children)
+	}
+
+	b.SetThreshold(Debug)
+	if c.EffectiveThreshold() != Debug || h.Get("a.d").EffectiveThreshold() != Warn {
+		t.Error("unexpected effective thresholds")
+	}
+
+	var msgs msgSlice
+	b.AddOutput(&msgs)
+	if outputs := b.Outputs(); len(outputs) != 1 || outputs[0] != &msgs || len(c.Outputs()) != 0 {
+		t.Errorf("unexpected outputs: %v", outputs)
+	}
+}
+
+func TestThresholdInheritance(t *testing.T) {
+	h := NewHierarchy()
+	abc := h.Get("a.b.c")
+	if abc.EffectiveThreshold() != Undefined {
+		t.Error("unconfigured logger has a threshold")
+	}
+	h.Root.SetThreshold(Info)
+	if abc.EffectiveThreshold() != Info {
+		t.Error("root threshold was not inherited")
+	}
+
+	// Setting a threshold cascades to descendants without their own threshold
+	a, ab := h.Get("a"), h.Get("a.b")
+	abc.SetThreshold(Error)
+	a.SetThreshold(Debug)
+	if ab.EffectiveThreshold() != Debug || abc.EffectiveThreshold() != Error {
+		t.Errorf("unexpected thresholds: %v, %v", ab.EffectiveThreshold(), abc.EffectiveThreshold())
+	}
+	if h.Get("a.b.d").EffectiveThreshold() != Debug {
+		t.Error("new logger did not inherit its ancestor's threshold")
+	}
+
+	// Clearing a threshold makes the logger inherit again
+	a.SetThreshold(Undefined)
+	if ab.EffectiveThreshold() != Info {
+		t.Errorf("unexpected threshold after clearing: %v", ab.EffectiveThreshold())
+	}
+
+	var msgs msgSlice
+	h.Root.AddOutput(&msgs)
+	ab.Debug("dropped")
+	a.SetThreshold(Trace)
+	ab.Debug("kept")
+	if len(msgs) != 1 || msgs[0].Msg != "kept" {
+		t.Errorf("unexpected messages: %v", msgs)
+	}
+
+	// Changes also cascade after the threshold has been resolved and cached
+	h.Get("a").SetThreshold(Warn)
+	if ab.EffectiveThreshold() != Warn {
+		t.Errorf("changed threshold was not inherited: %v", ab.EffectiveThreshold())
+	}
+	ab.Info("dropped")
+	if len(msgs) != 1 {
+		t.Errorf("unexpected messages: %v", msgs)
+	}
+	cancel := h.Elevate("a", Debug, 0)
+	if ab.EffectiveThreshold() != Debug {
+		t.Errorf("elevation was not inherited: %v", ab.EffectiveThreshold())
+	}
+	h.Get("a").SetThreshold(Trace)
+	if ab.EffectiveThreshold() != Trace {
+		t.Errorf("changed threshold was not inherited while elevated: %v", ab.EffectiveThreshold())
+	}
+	cancel()
+}
+
+func TestThresholdConcurrentChanges(t *testing.T) {
+	h := NewHierarchy()
+	h.Root.AddOutput(OutputterFunc(func(msg *Message) {}))
+	a, abc := h.Get("a"), h.Get("a.b.c")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				abc.Debug("message")
+			}
+		}()
+	}
+	for j := 0; j < 1000; j++ {
+		a.SetThreshold(Level(-101 - 100*(j%7)))
+	}
+	wg.Wait()
+	a.SetThreshold(Warn)
+	if abc.EffectiveThreshold() != Warn {
+		t.Errorf("last threshold was not applied: %v", abc.EffectiveThreshold())
+	}
+}
@@ -0,0 +1,283 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The body formats supported by HTTPSender.
+const (
+	// Newline-delimited JSON, with one formatted message per line.
+	HTTPFormatNDJSON = "ndjson"
+	// A JSON array, with one formatted message per element. Formatted messages that are not valid JSON are included as
+	// strings.
+	HTTPFormatArray = "array"
+)
+
+// HTTPSender implements BatchSender by sending each batch of messages in the body of an HTTP request, such as to a
+// webhook or to the bulk API of a log store.
+type HTTPSender struct {
+	// The URL that batches are sent to.
+	URL string
+	// The request method. Defaults to POST.
+	Method string
+	// Either HTTPFormatNDJSON (the default) or HTTPFormatArray.
+	Format string
+	// Formats each message in the batch.
+	Formatter Formatter
+	// A line that is written before each message in the NDJSON format, such as the action line of the Elasticsearch bulk
+	// API.
+	Prefix string
+	// The Content-Type header. Defaults to "application/x-ndjson" or "application/json", depending on the format.
+	ContentType string
+	// Extra headers to send with each request, such as for authentication.
+	Headers map[string]string
+	// Whether request bodies are compressed with gzip.
+	Gzip bool
+	// The client used to send requests. Defaults to DefaultHTTPClient.
+	Client *http.Client
+}
+
+// The client used to send batches when no other client is given. Unlike http.DefaultClient, it gives up on requests
+// after 30 seconds, so that a destination that never responds cannot stall a BatchOutputter.
+var DefaultHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Returns the body of the request for a batch, before it is compressed.
+func (s *HTTPSender) Body(msgs []*Message) ([]byte, error) {
+	var body bytes.Buffer
+	switch s.Format {
+	case "", HTTPFormatNDJSON:
+		for _, msg := range msgs {
+			if s.Prefix != "" {
+				body.WriteString(s.Prefix)
+				body.WriteByte('\n')
+			}
+			body.WriteString(strings.TrimRight(s.Formatter.Format(msg), "\n"))
+			body.WriteByte('\n')
+		}
+	case HTTPFormatArray:
+		elements := make([]json.RawMessage, len(msgs))
+		for i, msg := range msgs {
+			formatted := []byte(strings.TrimSpace(s.Formatter.Format(msg)))
+			if !json.Valid(formatted) {
+				formatted, _ = json.Marshal(string(formatted))
+			}
+			elements[i] = formatted
+		}
+		data, err := json.Marshal(elements)
+		if err != nil {
+			return nil, err
+		}
+		body.Write(data)
+	default:
+		return nil, errors.New("unknown http format: " + s.Format)
+	}
+	return body.Bytes(), nil
+}
+
+// Implements BatchSender. Responses with a status of 429 (Too Many Requests) or 5xx are retried (after the delay in their
+// Retry-After header, if there is one), while other failures are returned as PermanentError.
+func (s *HTTPSender) SendBatch(msgs []*Message) error {
+	body, err := s.Body(msgs)
+	if err != nil {
+		return PermanentError{err}
+	}
+	if s.Gzip {
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		writer.Write(body)
+		writer.Close()
+		body = compressed.Bytes()
+	}
+
+	method := s.Method
+	if method == "" {
+		method = "POST"
+	}
+	req, err := http.NewRequest(method, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return PermanentError{err}
+	}
+	contentType := s.ContentType
+	if contentType == "" {
+		contentType = "application/x-ndjson"
+		if s.Format == HTTPFormatArray {
+			contentType = "application/json"
+		}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range s.Headers {
+		req.Header.Set(key, value)
+	}
+	return SendHTTPBatch(s.Client, req, "http output")
+}
+
+// Sends a request for a BatchSender, with DefaultHTTPClient if client is nil, and converts an unsuccessful response
+// to an error that names the operation. Responses with a status of 429 (Too Many Requests) or 5xx are retried after the
+// delay in their Retry-After header (see RetryAfterError), while other failures are returned as PermanentError.
+func SendHTTPBatch(client *http.Client, req *http.Request, operation string) error {
+	if client == nil {
+		client = DefaultHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	status := resp.Status
+	if message := strings.TrimSpace(string(message)); message != "" {
+		status += ": " + message
+	}
+	err = fmt.Errorf("%s failed: %s", operation, status)
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+		return PermanentError{err}
+	}
+	if after, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		return RetryAfterError{err, after}
+	}
+	return err
+}
+
+// Parses a Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if after := date.Sub(now); after > 0 {
+			return after, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// Returns a function for BatchOptions.OnDrop that appends the body of each dropped batch to a file, so that the
+// messages can be sent again later. Errors that occur while writing the file are reported like output errors (see
+// OnError), under the name "dead_letter:" followed by the path.
+func DeadLetterFile(path string, sender *HTTPSender) func(msgs []*Message, err error) {
+	var lock sync.Mutex
+	output := NamedOutputter{Name: "dead_letter:" + path}
+	return func(msgs []*Message, err error) {
+		if err := appendDeadLetters(&lock, path, sender, msgs); err != nil {
+			reportErrors(output, msgs, err)
+		}
+	}
+}
+
+func appendDeadLetters(lock *sync.Mutex, path string, sender *HTTPSender, msgs []*Message) error {
+	body, err := sender.Body(msgs)
+	if err != nil {
+		return err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		body = append(body, '\n')
+	}
+	_, err = file.Write(body)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Parses a list in the form "key=value, key=value", such as the headers option of the "http" output. It is exported
+// for output plugins in other packages.
+func ParseKeyValues(list string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i <= 0 {
+			return nil, errors.New("invalid key=value pair: " + item)
+		}
+		result[strings.TrimSpace(item[:i])] = strings.TrimSpace(item[i+1:])
+	}
+	return result, nil
+}
+
+var httpPlugin = OutputPluginFunc(func(options map[string]string) (Outputter, error) {
+	s := &HTTPSender{
+		URL:         options["url"],
+		Method:      options["method"],
+		Format:      options["body"],
+		Prefix:      options["prefix"],
+		ContentType: options["content_type"],
+		Gzip:        options["gzip"] == "true",
+	}
+	if s.URL == "" {
+		return nil, errors.New("http url not specified")
+	}
+	if s.Format != "" && s.Format != HTTPFormatNDJSON && s.Format != HTTPFormatArray {
+		return nil, errors.New("unknown http body format: " + s.Format)
+	}
+
+	// Messages are formatted as JSON unless another formatter is chosen
+	formatOptions := options
+	if options["formatter"] == "" {
+		formatOptions = map[string]string{"formatter": "json", "timeformat": options["timeformat"]}
+	}
+	var err error
+	if s.Formatter, err = CreateFormatter(formatOptions); err != nil {
+		return nil, err
+	}
+
+	if s.Headers, err = ParseKeyValues(options["headers"]); err != nil {
+		return nil, err
+	}
+	token, username, password := options["bearer_token"], options["username"], options["password"]
+	if token != "" && (username != "" || password != "") {
+		return nil, errors.New("http bearer_token cannot be used with username and password")
+	}
+	if token != "" {
+		s.Headers["Authorization"] = "Bearer " + token
+	}
+	if username != "" {
+		s.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+	if timeout, ok := options["timeout"]; ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.New("invalid timeout: " + timeout)
+		}
+		s.Client = &http.Client{Timeout: d}
+	}
+
+	batch, err := ParseBatchOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	if path := options["dead_letter"]; path != "" {
+		batch.OnDrop = DeadLetterFile(path, s)
+	}
+	return NewBatchOutputter(s, batch), nil
+})
@@ -0,0 +1,167 @@
+package logging
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHTTPOutput(t *testing.T) {
+	var lock sync.Mutex
+	var bodies []string
+	var headers []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			reader, _ = gzip.NewReader(r.Body)
+		}
+		body, _ := io.ReadAll(reader)
+		lock.Lock()
+		defer lock.Unlock()
+		bodies = append(bodies, string(body))
+		headers = append(headers, r.Header)
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	h := NewHierarchy()
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, webhook
+
+  [webhook]
+  type = http
+  url = ` + server.URL + `
+  prefix = {"index":{}}
+  gzip = true
+  headers = X-Source=test
+  bearer_token = secret
+  batch_interval = 1h
+  retry_backoff = 1ms
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Get("a").Info("one")
+	h.Get("a").Warn("two")
+	h.Flush()
+
+	if len(bodies) != 2 {
+		t.Fatalf("expected a retried request, got %d requests", len(bodies))
+	}
+	lines := strings.Split(strings.TrimSpace(bodies[1]), "\n")
+	if len(lines) != 4 || lines[0] != `{"index":{}}` || !strings.Contains(lines[3], `"msg":"two"`) {
+		t.Errorf("unexpected body: %s", bodies[1])
+	}
+	if h := headers[1]; h.Get("Content-Type") != "application/x-ndjson" || h.Get("X-Source") != "test" || h.Get("Authorization") != "Bearer secret" {
+		t.Errorf("unexpected headers: %v", h)
+	}
+
+	// Credentials are not dumped
+	var dump strings.Builder
+	if err := h.DumpConfig(&dump); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(dump.String(), "secret") || strings.Contains(dump.String(), "X-Source") {
+		t.Errorf("credentials were dumped:\n%s", dump.String())
+	}
+
+	// Only one kind of authentication can be chosen
+	_, err = httpPlugin.CreateOutputter(map[string]string{"url": server.URL, "bearer_token": "secret", "username": "user"})
+	if err == nil {
+		t.Error("expected an error for both a bearer token and a username")
+	}
+}
+
+func TestHTTPArrayAndDeadLetter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	sender := &HTTPSender{URL: server.URL, Format: HTTPFormatArray, Formatter: NewBasicFormatter("$msg")}
+	msgs := []*Message{{Msg: "plain", Logger: Root}, {Msg: `{"json":true}`, Logger: Root}}
+	body, err := sender.Body(msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var elements []interface{}
+	if err := json.Unmarshal(body, &elements); err != nil || len(elements) != 2 || elements[0] != "plain" {
+		t.Errorf("unexpected array body: %s", body)
+	}
+
+	path := filepath.Join(t.TempDir(), "dead.json")
+	out := NewBatchOutputter(sender, BatchOptions{OnDrop: DeadLetterFile(path, sender), MaxRetries: -1})
+	for _, msg := range msgs {
+		out.Output(msg)
+	}
+	out.Close()
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != string(body)+"\n" {
+		t.Errorf("unexpected dead letter file: %q, %v", data, err)
+	}
+}
+
+func TestSendHTTPBatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if after := r.URL.Query().Get("after"); after != "" {
+			w.Header().Set("Retry-After", after)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "overloaded\n")
+	}))
+	defer server.Close()
+
+	sender := &HTTPSender{URL: server.URL + "?after=7", Formatter: NewBasicFormatter("$msg")}
+	err := sender.SendBatch([]*Message{{Msg: "x", Logger: Root}})
+	retryAfter, ok := err.(RetryAfterError)
+	if !ok || retryAfter.After != 7*time.Second || err.Error() != "http output failed: 503 Service Unavailable: overloaded" {
+		t.Errorf("unexpected error: %#v", err)
+	}
+	sender.URL = server.URL
+	if _, ok := sender.SendBatch([]*Message{{Msg: "x", Logger: Root}}).(RetryAfterError); ok {
+		t.Error("unexpected RetryAfterError without a Retry-After header")
+	}
+
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	for value, expect := range map[string]time.Duration{
+		"120":                           2 * time.Minute,
+		"Tue, 02 Jan 2024 15:00:30 GMT": 30 * time.Second,
+		"Tue, 02 Jan 2024 14:00:00 GMT": 0,
+	} {
+		if after, ok := parseRetryAfter(value, now); !ok || after != expect {
+			t.Errorf("unexpected delay for %q: %v", value, after)
+		}
+	}
+	if _, ok := parseRetryAfter("soon", now); ok {
+		t.Error("invalid Retry-After header was accepted")
+	}
+}
+
+func TestDeadLetterFileErrors(t *testing.T) {
+	var errs []string
+	OnError(func(output string, err error) {
+		errs = append(errs, output)
+	})
+	defer OnError(nil)
+
+	path := filepath.Join(t.TempDir(), "missing", "dead.json")
+	onDrop := DeadLetterFile(path, &HTTPSender{Formatter: NewBasicFormatter("$msg")})
+	onDrop([]*Message{{Msg: "x", Logger: Root}}, errors.New("unavailable"))
+	onDrop = DeadLetterFile(path, &HTTPSender{Format: "xml", Formatter: NewBasicFormatter("$msg")})
+	onDrop([]*Message{{Msg: "x", Logger: Root}}, errors.New("unavailable"))
+	if len(errs) != 2 || errs[0] != "dead_letter:"+path {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
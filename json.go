@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"encoding/json"
+	"path"
+	"time"
+)
+
+// JSONFormatter formats messages as single-line JSON objects. Stack traces and errors passed to the logging statement
+// are included as the "stack" and "errors" fields, where each error is rendered as its chain of wrapped errors (see
+// ErrorChain). Messages logged with tracing enabled (see WithTracing) have a "traced" field set to true, and the
+// message's Fields are included as the "fields" object.
+type JSONFormatter struct {
+	// The layout of the "time" field, as accepted by time.Time.Format. The names "rfc3339", "rfc3339nano", "unix",
+	// "unixmilli" and "unixnano" can be used too. Defaults to time.RFC3339Nano.
+	TimeLayout string
+}
+
+type jsonMessage struct {
+	Time   string            `json:"time"`
+	Level  string            `json:"level"`
+	Logger string            `json:"logger"`
+	File   string            `json:"file"`
+	Line   int               `json:"line"`
+	Msg    string            `json:"msg"`
+	Stack  string            `json:"stack,omitempty"`
+	Errors [][]ErrorInfo     `json:"errors,omitempty"`
+	Traced bool              `json:"traced,omitempty"`
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// Implements Formatter.
+func (j *JSONFormatter) Format(msg *Message) string {
+	layout := j.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	out := jsonMessage{
+		Time:   formatTime(msg.Time, layout),
+		Level:  msg.Level.String(),
+		Logger: msg.Logger.Name,
+		File:   path.Base(msg.File),
+		Line:   msg.Line,
+		Msg:    msg.Msg,
+		Stack:  msg.Stack,
+		Traced: msg.Traced,
+		Fields: msg.Fields,
+	}
+	for _, err := range msg.Errors {
+		out.Errors = append(out.Errors, ErrorChain(err))
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return `{"msg":"json formatting error"}`
+	}
+	return string(data)
+}
@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// MergedConfig implements Config by layering several Configs over each other. It is created by MergeConfigs.
+type MergedConfig struct {
+	loggers map[string]string
+	plugins []PluginConfig
+}
+
+// Merges Configs in order, so that settings from later Configs take precedence. Logger settings replace those of
+// earlier Configs, while output sections are merged option by option. An output section that changes the type of an
+// inherited section replaces it instead, since the inherited options belong to the old type.
+//
+// A logger setting whose name starts with "-" deletes the inherited setting for that logger, and an output section
+// whose name starts with "-" deletes the inherited output section.
+func MergeConfigs(configs ...Config) *MergedConfig {
+	m := &MergedConfig{loggers: make(map[string]string)}
+	for _, config := range configs {
+		for name, setting := range config.LoggerSettings() {
+			if strings.HasPrefix(name, "-") {
+				delete(m.loggers, name[1:])
+			} else {
+				m.loggers[name] = setting
+			}
+		}
+		for _, plugin := range config.Plugins() {
+			if strings.HasPrefix(plugin.Name, "-") {
+				m.deletePlugin(plugin.Name[1:])
+			} else {
+				m.mergePlugin(plugin)
+			}
+		}
+	}
+	return m
+}
+
+func (m *MergedConfig) deletePlugin(name string) {
+	for i, plugin := range m.plugins {
+		if plugin.Name == name {
+			m.plugins = append(m.plugins[:i], m.plugins[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *MergedConfig) mergePlugin(plugin PluginConfig) {
+	options := make(map[string]string, len(plugin.Options))
+	for key, value := range plugin.Options {
+		options[key] = value
+	}
+	for i, existing := range m.plugins {
+		if existing.Name != plugin.Name {
+			continue
+		}
+		if typ, ok := options["type"]; ok && typ != existing.Options["type"] {
+			m.plugins[i].Options = options
+			return
+		}
+		for key, value := range options {
+			existing.Options[key] = value
+		}
+		return
+	}
+	m.plugins = append(m.plugins, PluginConfig{
+		Name:    plugin.Name,
+		Options: options,
+	})
+}
+
+// Implements Config.
+func (m *MergedConfig) LoggerSettings() map[string]string {
+	return m.loggers
+}
+
+// Implements Config.
+func (m *MergedConfig) Plugins() []PluginConfig {
+	return m.plugins
+}
+
+// Loads several configuration files (see SetupFile), along with the files they include, and merges them in order with
+// MergeConfigs.
+func loadFiles(filenames ...string) (*MergedConfig, error) {
+	var layers []Config
+	for _, filename := range filenames {
+		fileLayers, err := loadLayers(filename, make(map[string]bool))
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, fileLayers...)
+	}
+	return MergeConfigs(layers...), nil
+}
+
+// Loads a configuration file, preceded by the files it includes. An INI file may include other files with an "include"
+// option at the top of the file, before any sections. Its value is a comma-separated list of files, relative to the
+// including file, which are merged in order before the including file itself.
+func loadLayers(filename string, including map[string]bool) ([]Config, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	if including[abs] {
+		return nil, errors.New("configuration file includes itself: " + filename)
+	}
+	config, err := loadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	iniConfig, ok := config.(IniConfig)
+	if !ok {
+		return []Config{config}, nil
+	}
+
+	including[abs] = true
+	defer delete(including, abs)
+	var layers []Config
+	for _, include := range strings.Split(iniConfig[""]["include"], ",") {
+		if include = strings.TrimSpace(include); include == "" {
+			continue
+		}
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		includeLayers, err := loadLayers(include, including)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, includeLayers...)
+	}
+	return append(layers, config), nil
+}
+
+// Configures the logging hierarchy from several files, which are merged in order so that settings from later files
+// take precedence (see MergeConfigs).
+func (h *Hierarchy) SetupFiles(filenames ...string) error {
+	config, err := loadFiles(filenames...)
+	if err != nil {
+		return err
+	}
+	return h.SetupConfig(config)
+}
+
+// Configures the Default hierarchy from several files (see Hierarchy.SetupFiles).
+func SetupFiles(filenames ...string) error {
+	return Default.SetupFiles(filenames...)
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSetupFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("base.ini", `
+[loggers]
+root = INFO, console
+db = DEBUG, console
+cache = TRACE, audit
+
+[console]
+type = console
+stream = stderr
+format = $msg
+
+[logfile]
+type = file
+file = `+filepath.Join(dir, "base.log")+`
+format = $msg
+
+[audit]
+type = console
+format = $msg
+`)
+	service := write("service.ini", `
+include = base.ini
+
+[loggers]
+root = WARN, console, logfile
+-cache =
+
+[logfile]
+file = `+filepath.Join(dir, "service.log")+`
+
+[-audit]
+`)
+	env := write("production.ini", `
+[loggers]
+db = ERROR
+
+[console]
+type = file
+file = `+filepath.Join(dir, "console.log")+`
+format = $level $msg
+`)
+
+	config, err := loadFiles(service, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLoggers := map[string]string{
+		"root": "WARN, console, logfile",
+		"db":   "ERROR",
+	}
+	if !reflect.DeepEqual(config.LoggerSettings(), expectLoggers) {
+		t.Errorf("unexpected logger settings: %v", config.LoggerSettings())
+	}
+	// The console section changed type, so its console options were not inherited
+	expectOutputs := []PluginConfig{
+		{"console", map[string]string{"type": "file", "file": filepath.Join(dir, "console.log"), "format": "$level $msg"}},
+		{"logfile", map[string]string{"type": "file", "file": filepath.Join(dir, "service.log"), "format": "$msg"}},
+	}
+	// The sections of an INI file are not ordered
+	plugins := config.Plugins()
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	if !reflect.DeepEqual(plugins, expectOutputs) {
+		t.Errorf("unexpected outputs: %v", plugins)
+	}
+
+	h := NewHierarchy()
+	if err := h.SetupFiles(service, env); err != nil {
+		t.Fatal(err)
+	}
+	h.Get("db").Error("layered")
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"console.log", "service.log"} {
+		if data, _ := os.ReadFile(filepath.Join(dir, name)); !strings.Contains(string(data), "layered") {
+			t.Errorf("message was not written to %s: %q", name, data)
+		}
+	}
+
+	write("loop.ini", "include = loop.ini\n")
+	if err := SetupFiles(filepath.Join(dir, "loop.ini")); err == nil {
+		t.Error("expected an error for a file that includes itself")
+	}
+}
@@ -0,0 +1,128 @@
+package logging
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The keys written by LogfmtFormatter when none are configured.
+var DefaultLogfmtKeys = []string{"time", "level", "logger", "caller", "msg", "error", "traced", "stack"}
+
+// The keys supported by LogfmtFormatter.
+var logfmtKeys = map[string]bool{
+	"time": true, "level": true, "logger": true, "caller": true, "file": true, "line": true, "msg": true,
+	"error": true, "traced": true, "stack": true,
+}
+
+// LogfmtFormatter formats messages as logfmt lines, such as:
+//
+//	time=2024-01-02T15:04:05Z level=INFO logger=app.db caller=db.go:42 msg="query took 3s"
+//
+// Values that contain whitespace (including Unicode spaces), quotes, equals signs or unprintable characters are quoted,
+// with the escapes used by Go string literals. The "error", "traced" and "stack" keys are left out of messages that have no errors, no tracing or no stack
+// trace.
+type LogfmtFormatter struct {
+	// The keys to write, in order: "time", "level", "logger", "caller" (the file and line), "file", "line", "msg",
+	// "error" (the errors passed to the logging statement), "traced" and "stack". Defaults to DefaultLogfmtKeys.
+	Keys []string
+	// The layout of the "time" key, as accepted by time.Time.Format. The names "rfc3339", "rfc3339nano", "unix",
+	// "unixmilli" and "unixnano" can be used too. Defaults to time.RFC3339Nano.
+	TimeLayout string
+}
+
+// Implements Formatter.
+func (l *LogfmtFormatter) Format(msg *Message) string {
+	keys := l.Keys
+	if keys == nil {
+		keys = DefaultLogfmtKeys
+	}
+	layout := l.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	var b strings.Builder
+	for _, key := range keys {
+		var value string
+		switch key {
+		case "time":
+			value = formatTime(msg.Time, layout)
+		case "level":
+			value = msg.Level.String()
+		case "logger":
+			if msg.Logger != nil {
+				value = msg.Logger.Name
+			}
+		case "caller":
+			value = path.Base(msg.File) + ":" + strconv.Itoa(msg.Line)
+		case "file":
+			value = path.Base(msg.File)
+		case "line":
+			value = strconv.Itoa(msg.Line)
+		case "msg":
+			value = msg.Msg
+		case "error":
+			if len(msg.Errors) == 0 {
+				continue
+			}
+			messages := make([]string, len(msg.Errors))
+			for i, err := range msg.Errors {
+				messages[i] = err.Error()
+			}
+			value = strings.Join(messages, "; ")
+		case "traced":
+			if !msg.Traced {
+				continue
+			}
+			value = "true"
+		case "stack":
+			if msg.Stack == "" {
+				continue
+			}
+			value = msg.Stack
+		default:
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(logfmtValue(value))
+	}
+	return b.String()
+}
+
+// Quotes a value if it cannot be written as it is.
+func logfmtValue(value string) string {
+	if value == "" {
+		return `""`
+	}
+	for _, r := range value {
+		if r <= ' ' || unicode.IsSpace(r) || !unicode.IsPrint(r) || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError {
+			return strconv.Quote(value)
+		}
+	}
+	return value
+}
+
+var logfmtFormatterPlugin = FormatterPlugin(func(options map[string]string) (Formatter, error) {
+	l := &LogfmtFormatter{TimeLayout: options["timeformat"]}
+	if list, ok := options["keys"]; ok {
+		l.Keys = []string{}
+		for _, key := range strings.Split(list, ",") {
+			if key = strings.TrimSpace(key); key == "" {
+				continue
+			}
+			if !logfmtKeys[key] {
+				return nil, errors.New("unknown logfmt key: " + key)
+			}
+			l.Keys = append(l.Keys, key)
+		}
+	}
+	return l, nil
+})
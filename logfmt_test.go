@@ -0,0 +1,118 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmtFormatter(t *testing.T) {
+	h := NewHierarchy()
+	msg := &Message{
+		Level:  Warn,
+		Msg:    `disk "data" is 95% full`,
+		Time:   time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		File:   "/src/app/disk.go",
+		Line:   17,
+		Logger: h.Get("app.disk"),
+	}
+	f := &LogfmtFormatter{}
+	expected := `time=2024-01-02T15:04:05Z level=WARN logger=app.disk caller=disk.go:17 msg="disk \"data\" is 95% full"`
+	if result := f.Format(msg); result != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", result, expected)
+	}
+
+	msg.Msg = "done"
+	msg.Errors = []error{errors.New("a=b"), errors.New("timeout")}
+	msg.Stack = "line 1\n\tline 2"
+	msg.Traced = true
+	f = &LogfmtFormatter{Keys: []string{"msg", "level", "line", "error", "traced", "stack"}, TimeLayout: "unix"}
+	expected = `msg=done level=WARN line=17 error="a=b; timeout" traced=true stack="line 1\n\tline 2"`
+	if result := f.Format(msg); result != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", result, expected)
+	}
+}
+
+func TestLogfmtValue(t *testing.T) {
+	values := map[string]string{
+		"":          `""`,
+		"plain":     "plain",
+		"héllo":     "héllo",
+		"a b":       `"a b"`,
+		"k=v":       `"k=v"`,
+		`back\path`: `"back\\path"`,
+		"bell\a":    `"bell\a"`,
+		"\xff":      `"\xff"`,
+		"a\u00a0b":  `"a\u00a0b"`,
+		"a\u2028b":  `"a\u2028b"`,
+		"a\u3000b":  `"a\u3000b"`,
+	}
+	for value, expected := range values {
+		if result := logfmtValue(value); result != expected {
+			t.Errorf("expected %q to be written as %s, got %s", value, expected, result)
+		}
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	tm := time.Date(2024, 1, 2, 15, 4, 5, 123456789, time.UTC)
+	layouts := map[string]string{
+		"rfc3339":     "2024-01-02T15:04:05Z",
+		"rfc3339nano": "2024-01-02T15:04:05.123456789Z",
+		"unix":        "1704207845",
+		"unixmilli":   "1704207845123",
+		"unixnano":    "1704207845123456789",
+		"15:04":       "15:04",
+	}
+	for layout, expected := range layouts {
+		if result := formatTime(tm, layout); result != expected {
+			t.Errorf("expected %s layout to give %s, got %s", layout, expected, result)
+		}
+	}
+
+	// The JSON formatter accepts the same names
+	f := &JSONFormatter{TimeLayout: "unix"}
+	if result := f.Format(&Message{Time: tm, Logger: Root}); !strings.Contains(result, `"time":"1704207845"`) {
+		t.Errorf("unexpected JSON time: %s", result)
+	}
+}
+
+func TestLogfmtPlugin(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "out.log")
+	h := NewHierarchy()
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, file
+
+  [file]
+  type = file
+  file = ` + file + `
+  formatter = logfmt
+  keys = level, logger, msg
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Get("a.b").Info("hello world")
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := string(data); result != "level=INFO logger=a.b msg=\"hello world\"\n" {
+		t.Errorf("unexpected output: %q", result)
+	}
+
+	formatter, err := CreateFormatter(map[string]string{"formatter": "logfmt", "keys": "level, msg", "timeformat": "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if options := describe(formatter); options["keys"] != "level, msg" || options["timeformat"] != "unix" {
+		t.Errorf("unexpected description: %v", options)
+	}
+	if _, err := CreateFormatter(map[string]string{"formatter": "logfmt", "keys": "msg, host"}); err == nil {
+		t.Error("expected an error for an unknown key")
+	}
+}
@@ -2,10 +2,16 @@
 package logging
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -49,6 +55,14 @@ func (l Level) String() string {
 	return fmt.Sprintf("LEVEL:%d", l)
 }
 
+// Parses the name of a level, such as "INFO". Names are not case sensitive.
+func ParseLevel(name string) (Level, error) {
+	if level, ok := reverseLevelStrings[strings.ToUpper(strings.TrimSpace(name))]; ok {
+		return level, nil
+	}
+	return Undefined, fmt.Errorf("unknown level: %s", name)
+}
+
 // A Message contains information about a logging event.
 type Message struct {
 	// The priority of the message.
@@ -63,12 +77,46 @@ type Message struct {
 	Line int
 	// The Logger which logged the message.
 	Logger *Logger
+	// The stack trace of the goroutine that logged the message, starting with the logging statement. Stack traces are
+	// only captured for messages that meet a stack trace threshold (see Logger.StackTrace).
+	Stack string
+	// Any errors that were passed as arguments to the logging statement.
+	Errors []error
+	// The context that the message was logged with (see Logger.WithContext), or nil.
+	Context context.Context
+	// Whether the message was logged with a context that has tracing enabled (see WithTracing).
+	Traced bool
+	// Additional information about the message, as key-value pairs, such as the request details attached by
+	// RecoverHandler.
+	Fields map[string]string
+	// Whether the stack trace was only captured because an output requires it (see StackOutputter), in which case it is
+	// removed before the message is sent to other outputs.
+	outputStack bool
 }
 
 func (m *Message) String() string {
 	return m.Msg
 }
 
+// Formats a time with a layout accepted by time.Time.Format, or with one of the named layouts: "rfc3339",
+// "rfc3339nano", and "unix", "unixmilli" or "unixnano" for the time since the Unix epoch in seconds, milliseconds or
+// nanoseconds.
+func formatTime(t time.Time, layout string) string {
+	switch layout {
+	case "rfc3339":
+		layout = time.RFC3339
+	case "rfc3339nano":
+		layout = time.RFC3339Nano
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixmilli":
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	case "unixnano":
+		return strconv.FormatInt(t.UnixNano(), 10)
+	}
+	return t.Format(layout)
+}
+
 // An Outputter is responsible for logging a message to some destination.
 type Outputter interface {
 	Output(msg *Message)
@@ -81,6 +129,23 @@ func (o OutputterFunc) Output(msg *Message) {
 	o(msg)
 }
 
+// An ErrorOutputter is an Outputter that can report whether a message was successfully output.
+type ErrorOutputter interface {
+	Outputter
+	// Outputs the message, returning any error that occurred.
+	OutputErr(msg *Message) error
+}
+
+// Sends a message to an Outputter. If the Outputter is an ErrorOutputter, then its OutputErr method is used and the
+// resulting error is returned, otherwise the Outputter is assumed to have succeeded.
+func OutputErr(o Outputter, msg *Message) error {
+	if eo, ok := o.(ErrorOutputter); ok {
+		return eo.OutputErr(msg)
+	}
+	o.Output(msg)
+	return nil
+}
+
 // A Formatter is responsible for converting a Message into a string representation. See BasicFormatter.
 type Formatter interface {
 	Format(msg *Message) string
@@ -90,212 +155,559 @@ type Formatter interface {
 type Logger struct {
 	// The full name of the logger.
 	Name string
-	// The minimum level a log message can have to be logged.
-	Threshold Level
-	// If true, log messages will not be propagated to the parent Logger's outputs. If false, log messages will be sent up
-	// the hierarchy until a Logger is found with the NoPropagate property set to true.
-	NoPropagate bool
-	parent      *Logger
-	children    map[string]*Logger
-	outputs     []Outputter
+	// The minimum level a log message must have for a stack trace to be captured. If Undefined, the StackTrace of the
+	// closest ancestor that has one is used, and if there is none, no stack traces are captured by this Logger unless an
+	// output requires them.
+	StackTrace Level
+	hierarchy  *Hierarchy
+	parent     *Logger
+	children   map[string]*Logger
+	elevations []Level
+	// The minimum level a log message can have to be logged, as an int32 (see SetThreshold).
+	threshold int32
+	// The attached outputs, as a []*attachment. The slice is replaced rather than modified, under the hierarchy's lock.
+	outputs atomic.Value
+	// Whether messages are kept from the outputs of ancestors (see SetNoPropagate), as 0 or 1.
+	noPropagate int32
+	filtered    uint64
+	// The number of messages logged at each standard level, from Fatal to Trace.
+	logged [7]uint64
+	// The key of the selector that configured the logger, if any.
+	selector string
+	// The level at which recovered panics are logged, and the action taken afterwards (see SetPanicLevel and
+	// SetOnPanic). Guarded by the hierarchy's lock.
+	panicLevel Level
+	onPanic    PanicAction
+	// The cached effective threshold (in the low 32 bits) and the hierarchy generation it was resolved in (in the high
+	// 32 bits).
+	cache uint64
 }
 
-func newLogger(name string, parent *Logger) *Logger {
+func newLogger(name string, parent *Logger, hierarchy *Hierarchy) *Logger {
 	return &Logger{
-		Name:     name,
-		parent:   parent,
-		children: make(map[string]*Logger),
+		Name:      name,
+		hierarchy: hierarchy,
+		parent:    parent,
+		children:  make(map[string]*Logger),
 	}
 }
 
 func (l *Logger) reset() {
-	l.Threshold = Undefined
-	l.NoPropagate = false
-	l.outputs = nil
+	atomic.StoreInt32(&l.threshold, int32(Undefined))
+	l.StackTrace = Undefined
+	atomic.StoreInt32(&l.noPropagate, 0)
+	l.outputs.Store([]*attachment(nil))
+	l.selector = ""
+	l.panicLevel = Undefined
+	l.onPanic = PanicInherit
+}
+
+// Reports whether a message with the given level meets the Logger's threshold. Messages that do not are counted as
+// filtered.
+func (l *Logger) enabled(level Level) bool {
+	if l.EffectiveThreshold() > level {
+		atomic.AddUint64(&l.filtered, 1)
+		return false
+	}
+	return true
 }
 
-func (l *Logger) log(level Level, msgstr string, stack int) {
+func (l *Logger) log(ctx context.Context, level Level, msgstr string, args []interface{}, stack int) {
 	msg := &Message{
-		Level:  level,
-		Msg:    msgstr,
-		Time:   time.Now(),
-		Logger: l,
+		Level:   level,
+		Msg:     msgstr,
+		Time:    time.Now(),
+		Logger:  l,
+		Context: ctx,
+		Traced:  IsTraced(ctx),
 	}
 	_, msg.File, msg.Line, _ = runtime.Caller(stack)
+	if capture, forOutputs := l.captureStack(level); capture {
+		msg.Stack = captureStack(stack + 1)
+		msg.outputStack = forOutputs
+	}
+	for _, arg := range args {
+		if err, ok := arg.(error); ok {
+			msg.Errors = append(msg.Errors, err)
+		}
+	}
+	recordLogged(l, level)
 	l.doLog(msg)
 }
 
 func (l *Logger) doLog(msg *Message) {
-	for _, output := range l.outputs {
-		output.Output(msg)
+	var stripped *Message
+	for _, attached := range l.attachments() {
+		output := attached.output
+		// Messages below an attachment's threshold are not sent to the output, so no latency is recorded for them
+		if thresh, ok := output.(ThresholdOutputter); ok && thresh.Threshold > msg.Level {
+			continue
+		}
+		msg := msg
+		if msg.outputStack && !wantsStack(output) {
+			if stripped == nil {
+				stripped = withoutStack(msg)
+			}
+			msg = stripped
+		}
+		name, hist := outputMetrics(output)
+		var start time.Time
+		if hist != nil {
+			start = time.Now()
+		}
+		err := OutputErr(output, msg)
+		if hist != nil {
+			recordLatency(name, hist, time.Since(start))
+		}
+		if err != nil {
+			reportError(output, msg, err)
+		}
 	}
-	if !l.NoPropagate && l.parent != nil {
+	if !l.NoPropagate() && l.parent != nil {
 		l.parent.doLog(msg)
 	}
 }
 
-// Adds an Outputter to the Logger. Subsequent Messages that exceed the logger's Threshold will be sent to the
+// An output that has been attached to a Logger. Each attachment is a separate pointer, so that it can be removed again
+// even if the Outputter itself cannot be compared.
+type attachment struct {
+	output Outputter
+}
+
+func (l *Logger) attachments() []*attachment {
+	attachments, _ := l.outputs.Load().([]*attachment)
+	return attachments
+}
+
+// Adds an attachment. The hierarchy's lock must be held.
+func (l *Logger) attach(a *attachment) {
+	attachments := l.attachments()
+	l.outputs.Store(append(attachments[:len(attachments):len(attachments)], a))
+}
+
+// Removes the first attachment that satisfies match, and reports whether there was one. The hierarchy's lock must be
+// held.
+func (l *Logger) detach(match func(a *attachment) bool) bool {
+	attachments := l.attachments()
+	for i, a := range attachments {
+		if match(a) {
+			l.outputs.Store(append(attachments[:i:i], attachments[i+1:]...))
+			return true
+		}
+	}
+	return false
+}
+
+// Reports whether messages are kept from the outputs of the Logger's ancestors.
+func (l *Logger) NoPropagate() bool {
+	return atomic.LoadInt32(&l.noPropagate) != 0
+}
+
+// Sets whether messages are kept from the outputs of the Logger's ancestors. If false, messages are sent up the
+// hierarchy until a Logger is found that does not propagate them.
+func (l *Logger) SetNoPropagate(noPropagate bool) {
+	var value int32
+	if noPropagate {
+		value = 1
+	}
+	atomic.StoreInt32(&l.noPropagate, value)
+}
+
+// Adds an Outputter to the Logger. Subsequent Messages that exceed the Logger's threshold will be sent to the
 // Outputter.
 func (l *Logger) AddOutput(o Outputter) {
-	l.outputs = append(l.outputs, o)
+	l.Attach(o)
+}
+
+// Adds an Outputter to the Logger with a threshold of its own. Messages are only sent to the Outputter if they exceed
+// both the Logger's threshold and the given threshold, so the same Outputter can be added to several loggers at different
+// levels.
+func (l *Logger) AddOutputThreshold(o Outputter, threshold Level) {
+	l.AddOutput(ThresholdOutputter{threshold, o})
+}
+
+// Adds an Outputter to the Logger, like AddOutput, and returns a function that removes it again. The function removes
+// exactly this attachment, so it works for Outputters that cannot be compared with ==, and it does nothing if the
+// attachment has already been removed, such as by a new configuration.
+func (l *Logger) Attach(o Outputter) (detach func()) {
+	a := &attachment{o}
+	h := l.hierarchy
+	h.lock.Lock()
+	l.attach(a)
+	h.lock.Unlock()
+	return func() {
+		h.lock.Lock()
+		defer h.lock.Unlock()
+		l.detach(func(attached *attachment) bool {
+			return attached == a
+		})
+	}
+}
+
+// Removes an Outputter that was added with AddOutput. The Outputter is found by comparing it with ==, so Outputters
+// of types that cannot be compared, such as TeeOutputter, are never found; use Attach to remove those.
+func (l *Logger) RemoveOutput(o Outputter) {
+	h := l.hierarchy
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	l.detach(func(attached *attachment) bool {
+		return sameOutput(attached.output, o)
+	})
+}
+
+// Compares two Outputters with ==, if their type allows it.
+func sameOutput(a, b Outputter) bool {
+	typ := reflect.TypeOf(a)
+	return typ == reflect.TypeOf(b) && typ != nil && typ.Comparable() && a == b
+}
+
+// Returns the Logger's parent, or nil for the root Logger.
+func (l *Logger) Parent() *Logger {
+	return l.parent
 }
 
-// Recursively makes child loggers with Undefined thresholds inherit their threshold from their parents.
-func (l *Logger) configure() {
+// Returns the Logger's direct children, sorted by name.
+func (l *Logger) Children() []*Logger {
+	l.hierarchy.lock.Lock()
+	defer l.hierarchy.lock.Unlock()
+	return l.sortedChildren()
+}
+
+func (l *Logger) sortedChildren() []*Logger {
+	children := make([]*Logger, 0, len(l.children))
 	for _, child := range l.children {
-		if child.Threshold == Undefined {
-			child.Threshold = l.Threshold
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
+
+// Returns the Outputters that have been added to the Logger. Outputters of ancestors that messages propagate to are
+// not included.
+func (l *Logger) Outputs() []Outputter {
+	var outputs []Outputter
+	for _, attached := range l.attachments() {
+		outputs = append(outputs, attached.output)
+	}
+	return outputs
+}
+
+// Returns the threshold that is applied to the Logger's messages, which is its own threshold, or the threshold of its
+// closest ancestor if its own is Undefined. If the Logger or an ancestor has been elevated (see Hierarchy.Elevate), the
+// most verbose elevation is used instead when it is lower. The result is cached until a threshold or elevation in the
+// hierarchy changes.
+func (l *Logger) EffectiveThreshold() Level {
+	h := l.hierarchy
+	generation := atomic.LoadUint64(&h.generation)
+	cache := atomic.LoadUint64(&l.cache)
+	if uint32(cache>>32) == uint32(generation) {
+		return Level(int32(uint32(cache)))
+	}
+	threshold := Undefined
+	for logger := l; logger != nil; logger = logger.parent {
+		if own := logger.Threshold(); own != Undefined {
+			threshold = own
+			break
 		}
-		child.configure()
 	}
+	if elevation := h.elevation(l); elevation != Undefined && (threshold == Undefined || elevation < threshold) {
+		threshold = elevation
+	}
+	atomic.StoreUint64(&l.cache, generation<<32|uint64(uint32(int32(threshold))))
+	return threshold
 }
 
-/* Global logger hierarchy */
+// Returns the Logger's own threshold, which is Undefined if it inherits the threshold of its parent.
+func (l *Logger) Threshold() Level {
+	return Level(atomic.LoadInt32(&l.threshold))
+}
 
-var lock sync.Mutex
-var loggers map[string]*Logger
-var configured bool
+// Sets the Logger's threshold: the minimum level a log message can have to be logged. It is inherited by descendants
+// that do not have a threshold of their own, and can be set to Undefined to make the Logger inherit its parent's
+// threshold again. It is safe to call SetThreshold while other goroutines are logging.
+func (l *Logger) SetThreshold(threshold Level) {
+	atomic.StoreInt32(&l.threshold, int32(threshold))
+	l.hierarchy.invalidate()
+}
+
+// Returns the level at which the Logger captures stack traces, which is its own StackTrace, or the StackTrace of its
+// closest ancestor if its own is Undefined.
+func (l *Logger) effectiveStackTrace() Level {
+	for logger := l; logger != nil; logger = logger.parent {
+		if logger.StackTrace != Undefined {
+			return logger.StackTrace
+		}
+	}
+	return Undefined
+}
+
+/* Logger hierarchies */
+
+// A Hierarchy is an independent tree of loggers, with its own configuration and output plugins. The package-level
+// functions, such as Get and SetupConfig, use the Default hierarchy.
+type Hierarchy struct {
+	// The root Logger. This is the ancestor of all loggers in the hierarchy.
+	Root *Logger
+
+	lock               sync.Mutex
+	loggers            map[string]*Logger
+	generation         uint64
+	outputStackTrace   int64
+	configuredSections map[string]map[string]string
+	selectors          []*loggerSelector
+	outputters         map[string]Outputter
+	closers            []io.Closer
+	elevated           int32
+	debugToggle        func()
+	outputPlugins      map[string]OutputPlugin
+	compositePlugins   map[string]CompositePlugin
+	pluginOptions      map[string][]string
+	referenceOptions   map[string][]string
+	secretOptions      map[string][]string
+}
 
-// The root Logger. This is the ancestor of all loggers.
-var Root = newLogger("root", nil)
+func newHierarchy() *Hierarchy {
+	h := &Hierarchy{
+		loggers:          make(map[string]*Logger),
+		generation:       1,
+		outputPlugins:    make(map[string]OutputPlugin),
+		compositePlugins: make(map[string]CompositePlugin),
+		pluginOptions:    make(map[string][]string),
+		referenceOptions: make(map[string][]string),
+		secretOptions:    make(map[string][]string),
+	}
+	h.Root = newLogger("root", nil, h)
+	return h
+}
+
+// Returns a new, unconfigured Hierarchy. The new hierarchy starts with the output plugins that are registered with the
+// Default hierarchy, but plugins registered afterwards are not shared.
+func NewHierarchy() *Hierarchy {
+	h := newHierarchy()
+	Default.lock.Lock()
+	defer Default.lock.Unlock()
+	for name, plugin := range Default.outputPlugins {
+		h.outputPlugins[name] = plugin
+	}
+	for name, plugin := range Default.compositePlugins {
+		h.compositePlugins[name] = plugin
+	}
+	for name, options := range Default.pluginOptions {
+		h.pluginOptions[name] = options
+	}
+	for name, options := range Default.referenceOptions {
+		h.referenceOptions[name] = options
+	}
+	for name, options := range Default.secretOptions {
+		h.secretOptions[name] = options
+	}
+	return h
+}
+
+// The default Hierarchy, which is used by the package-level functions.
+var Default = newHierarchy()
+
+// The root Logger of the Default hierarchy.
+var Root = Default.Root
+
+// Protects the registries that are shared by all hierarchies, such as formatter plugins.
+var lock sync.Mutex
 
 // Returns a Logger instance for the given logger name. A logger name consists of dot-separated parts, and is the basis
-// of the logger hierarchy. When loggers are created (implicitly by Get) they inherit their Threshold from
-func Get(fullname string) *Logger {
-	lock.Lock()
-	defer lock.Unlock()
-	if loggers != nil {
-		if logger := loggers[fullname]; logger != nil {
-			return logger
-		}
+// of the logger hierarchy. When loggers are created (implicitly by Get) they inherit their Threshold from their parent,
+// and any missing ancestors are created too.
+func (h *Hierarchy) Get(fullname string) *Logger {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.get(fullname)
+}
+
+// Like Get, but the hierarchy's lock must be held.
+func (h *Hierarchy) get(fullname string) *Logger {
+	if logger := h.loggers[fullname]; logger != nil {
+		return logger
 	}
 	// Go down the hierarchy, creating loggers where needed
 	parts := strings.Split(fullname, ".")
-	logger := Root
-	for _, part := range parts {
+	logger := h.Root
+	for i, part := range parts {
 		child := logger.children[part]
 		if child == nil {
-			child = newLogger(fullname, logger)
-			if configured {
-				child.Threshold = logger.Threshold
-			}
+			child = newLogger(strings.Join(parts[:i+1], "."), logger, h)
+			h.selectLogger(child)
 			logger.children[part] = child
 		}
 		logger = child
 	}
-	if loggers == nil {
-		loggers = make(map[string]*Logger)
-	}
-	loggers[fullname] = logger
+	h.loggers[fullname] = logger
 	return logger
 }
 
-func resetLoggers() {
-	lock.Lock()
-	defer lock.Unlock()
-	for _, logger := range loggers {
-		logger.reset()
+// Returns a Logger from the Default hierarchy (see Hierarchy.Get).
+func Get(fullname string) *Logger {
+	return Default.Get(fullname)
+}
+
+// Calls fn for every Logger in the hierarchy, starting with Root. Parents are visited before their children, and
+// children are visited in order of name. Loggers that are created by fn are not visited.
+func (h *Hierarchy) Walk(fn func(logger *Logger)) {
+	for _, logger := range h.Loggers() {
+		fn(logger)
 	}
-	Root.reset()
-	configured = false
+}
+
+// Calls fn for every Logger in the Default hierarchy (see Hierarchy.Walk).
+func Walk(fn func(logger *Logger)) {
+	Default.Walk(fn)
+}
+
+// Returns every Logger in the hierarchy, including Root and loggers that were created implicitly as ancestors, in the
+// order they are visited by Walk.
+func (h *Hierarchy) Loggers() []*Logger {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	var loggers []*Logger
+	h.Root.walk(func(logger *Logger) {
+		loggers = append(loggers, logger)
+	})
+	return loggers
+}
+
+// Calls fn for the Logger and its descendants, in the order of Walk. The hierarchy's lock must be held.
+func (l *Logger) walk(fn func(logger *Logger)) {
+	fn(l)
+	for _, child := range l.sortedChildren() {
+		child.walk(fn)
+	}
+}
+
+// Returns every Logger in the Default hierarchy (see Hierarchy.Loggers).
+func Loggers() []*Logger {
+	return Default.Loggers()
+}
+
+func (h *Hierarchy) reset() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.resetLocked()
+}
+
+// Like reset, but the hierarchy's lock must be held.
+func (h *Hierarchy) resetLocked() {
+	h.Root.walk((*Logger).reset)
+	atomic.StoreInt64(&h.outputStackTrace, int64(Undefined))
+	h.selectors = nil
+	h.outputters = nil
+	h.invalidate()
+}
+
+// Discards the cached effective thresholds of every Logger in the hierarchy.
+func (h *Hierarchy) invalidate() {
+	atomic.AddUint64(&h.generation, 1)
+}
+
+func resetLoggers() {
+	Default.reset()
 }
 
 /* Logging methods */
 
 func (l *Logger) Log(level Level, msgparts ...interface{}) {
-	if l.Threshold > level {
+	if !l.enabled(level) {
 		return
 	}
-	l.log(level, fmt.Sprint(msgparts...), 2)
+	l.log(nil, level, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Logf(level Level, format string, args ...interface{}) {
-	if l.Threshold > level {
+	if !l.enabled(level) {
 		return
 	}
-	l.log(level, fmt.Sprintf(format, args...), 2)
+	l.log(nil, level, fmt.Sprintf(format, args...), args, 2)
 }
 
 func (l *Logger) Fatal(msgparts ...interface{}) {
-	if l.Threshold > Fatal {
+	if !l.enabled(Fatal) {
 		return
 	}
-	l.log(Fatal, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Fatal, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Fatalf(format string, args ...interface{}) {
-	if l.Threshold > Fatal {
+	if !l.enabled(Fatal) {
 		return
 	}
-	l.log(Fatal, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Fatal, fmt.Sprintf(format, args...), args, 2)
 }
 func (l *Logger) Error(msgparts ...interface{}) {
-	if l.Threshold > Error {
+	if !l.enabled(Error) {
 		return
 	}
-	l.log(Error, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Error, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	if l.Threshold > Error {
+	if !l.enabled(Error) {
 		return
 	}
-	l.log(Error, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Error, fmt.Sprintf(format, args...), args, 2)
 }
 func (l *Logger) Warn(msgparts ...interface{}) {
-	if l.Threshold > Warn {
+	if !l.enabled(Warn) {
 		return
 	}
-	l.log(Warn, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Warn, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Warnf(format string, args ...interface{}) {
-	if l.Threshold > Warn {
+	if !l.enabled(Warn) {
 		return
 	}
-	l.log(Warn, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Warn, fmt.Sprintf(format, args...), args, 2)
 }
 func (l *Logger) Notice(msgparts ...interface{}) {
-	if l.Threshold > Notice {
+	if !l.enabled(Notice) {
 		return
 	}
-	l.log(Notice, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Notice, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Noticef(format string, args ...interface{}) {
-	if l.Threshold > Notice {
+	if !l.enabled(Notice) {
 		return
 	}
-	l.log(Notice, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Notice, fmt.Sprintf(format, args...), args, 2)
 }
 func (l *Logger) Info(msgparts ...interface{}) {
-	if l.Threshold > Info {
+	if !l.enabled(Info) {
 		return
 	}
-	l.log(Info, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Info, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Infof(format string, args ...interface{}) {
-	if l.Threshold > Info {
+	if !l.enabled(Info) {
 		return
 	}
-	l.log(Info, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Info, fmt.Sprintf(format, args...), args, 2)
 }
 func (l *Logger) Debug(msgparts ...interface{}) {
-	if l.Threshold > Debug {
+	if !l.enabled(Debug) {
 		return
 	}
-	l.log(Debug, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Debug, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Debugf(format string, args ...interface{}) {
-	if l.Threshold > Debug {
+	if !l.enabled(Debug) {
 		return
 	}
-	l.log(Debug, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Debug, fmt.Sprintf(format, args...), args, 2)
 }
 func (l *Logger) Trace(msgparts ...interface{}) {
-	if l.Threshold > Trace {
+	if !l.enabled(Trace) {
 		return
 	}
-	l.log(Trace, fmt.Sprint(msgparts...), 2)
+	l.log(nil, Trace, fmt.Sprint(msgparts...), msgparts, 2)
 }
 func (l *Logger) Tracef(format string, args ...interface{}) {
-	if l.Threshold > Trace {
+	if !l.enabled(Trace) {
 		return
 	}
-	l.log(Trace, fmt.Sprintf(format, args...), 2)
+	l.log(nil, Trace, fmt.Sprintf(format, args...), args, 2)
 }
@@ -57,3 +57,14 @@ func TestMultipleSetup(t *testing.T) {
 	mockSetup()
 	checkLogs()
 }
+
+func TestParseLevel(t *testing.T) {
+	for _, name := range []string{"WARN", "warn", " Warn "} {
+		if level, err := ParseLevel(name); err != nil || level != Warn {
+			t.Errorf("unexpected result for %q: %v %v", name, level, err)
+		}
+	}
+	if _, err := ParseLevel("LOUD"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
@@ -0,0 +1,213 @@
+// Package logtest provides helpers for testing code that logs with go-logging.
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Recorder implements logging.Outputter by recording every message it receives. It is safe for concurrent use.
+type Recorder struct {
+	t        testing.TB
+	lock     sync.Mutex
+	messages []*logging.Message
+	// The logger that the Recorder was attached to by Capture, if any.
+	logger *logging.Logger
+}
+
+// Returns a new Recorder. The assertion methods report failures to t.
+func NewRecorder(t testing.TB) *Recorder {
+	return &Recorder{t: t}
+}
+
+// Implements logging.Outputter.
+func (r *Recorder) Output(msg *logging.Message) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.messages = append(r.messages, msg)
+}
+
+// Returns the messages that have been recorded so far.
+func (r *Recorder) Messages() []*logging.Message {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]*logging.Message(nil), r.messages...)
+}
+
+// Forgets all recorded messages.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.messages = nil
+}
+
+// Returns the recorded messages that satisfy every matcher.
+func (r *Recorder) Find(matchers ...Matcher) (found []*logging.Message) {
+	for _, msg := range r.Messages() {
+		if matchAll(msg, matchers) {
+			found = append(found, msg)
+		}
+	}
+	return
+}
+
+// Fails the test unless a message with the given level and containing the given substring has been recorded.
+func (r *Recorder) AssertLogged(level logging.Level, substring string) {
+	r.t.Helper()
+	r.AssertMatch(AtLevel(level), MsgContains(substring))
+}
+
+// Fails the test if a message with the given level and containing the given substring has been recorded.
+func (r *Recorder) AssertNotLogged(level logging.Level, substring string) {
+	r.t.Helper()
+	r.AssertNoMatch(AtLevel(level), MsgContains(substring))
+}
+
+// Fails the test unless a message satisfying every matcher has been recorded.
+func (r *Recorder) AssertMatch(matchers ...Matcher) {
+	r.t.Helper()
+	if len(r.Find(matchers...)) == 0 {
+		r.t.Errorf("no message matching %s was logged; got:\n%s", describeMatchers(matchers), r.dump())
+	}
+}
+
+// Fails the test if a message satisfying every matcher has been recorded.
+func (r *Recorder) AssertNoMatch(matchers ...Matcher) {
+	r.t.Helper()
+	if found := r.Find(matchers...); len(found) > 0 {
+		r.t.Errorf("unexpected message matching %s was logged: [%s] %s", describeMatchers(matchers), found[0].Level, found[0].Msg)
+	}
+}
+
+func (r *Recorder) dump() string {
+	var lines []string
+	for _, msg := range r.Messages() {
+		lines = append(lines, fmt.Sprintf("\t[%s] %s: %s", msg.Level, msg.Logger.Name, msg.Msg))
+	}
+	if len(lines) == 0 {
+		return "\t(nothing)"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Attaches a new Recorder to the named logger ("root" for the root logger), and lowers the logger's threshold so that
+// every message is recorded. Captured messages are still sent to the outputs of the logger's ancestors, unless Isolate
+// is used. When the test finishes, the Recorder is removed and the logger's previous threshold is restored.
+func Capture(t testing.TB, loggerName string) *Recorder {
+	return CaptureIn(t, logging.Default, loggerName)
+}
+
+// Like Capture, but for a logger in the given hierarchy.
+func CaptureIn(t testing.TB, h *logging.Hierarchy, loggerName string) *Recorder {
+	logger := h.Root
+	if loggerName != "root" {
+		logger = h.Get(loggerName)
+	}
+	recorder := NewRecorder(t)
+	recorder.logger = logger
+	threshold := logger.Threshold()
+	logger.SetThreshold(logging.Trace)
+	detach := logger.Attach(recorder)
+	t.Cleanup(func() {
+		detach()
+		logger.SetThreshold(threshold)
+	})
+	return recorder
+}
+
+// Stops the captured logger's messages from being sent to the outputs of its ancestors, until the test finishes. It
+// returns the Recorder, so that it can be chained with Capture. Isolate can only be used with a Recorder returned by
+// Capture or CaptureIn.
+func (r *Recorder) Isolate() *Recorder {
+	logger := r.logger
+	noPropagate := logger.NoPropagate()
+	logger.SetNoPropagate(true)
+	r.t.Cleanup(func() {
+		logger.SetNoPropagate(noPropagate)
+	})
+	return r
+}
+
+// A Matcher reports whether a message has some property.
+type Matcher struct {
+	Description string
+	Match       func(msg *logging.Message) bool
+}
+
+func matchAll(msg *logging.Message, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.Match(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeMatchers(matchers []Matcher) string {
+	descriptions := make([]string, len(matchers))
+	for i, matcher := range matchers {
+		descriptions[i] = matcher.Description
+	}
+	return "{" + strings.Join(descriptions, ", ") + "}"
+}
+
+// Matches messages with the given level.
+func AtLevel(level logging.Level) Matcher {
+	return Matcher{"level " + level.String(), func(msg *logging.Message) bool {
+		return msg.Level == level
+	}}
+}
+
+// Matches messages whose text contains the substring.
+func MsgContains(substring string) Matcher {
+	return Matcher{fmt.Sprintf("msg containing %q", substring), func(msg *logging.Message) bool {
+		return strings.Contains(msg.Msg, substring)
+	}}
+}
+
+// Matches messages that were logged by the named logger.
+func FromLogger(name string) Matcher {
+	return Matcher{"logger " + name, func(msg *logging.Message) bool {
+		return msg.Logger.Name == name
+	}}
+}
+
+// Matches messages that were logged with an error argument that matches target, according to errors.Is.
+func WithError(target error) Matcher {
+	return Matcher{fmt.Sprintf("error %q", target), func(msg *logging.Message) bool {
+		for _, err := range msg.Errors {
+			if errors.Is(err, target) {
+				return true
+			}
+		}
+		return false
+	}}
+}
+
+// Matches messages that have a stack trace.
+func WithStack() Matcher {
+	return Matcher{"with stack trace", func(msg *logging.Message) bool {
+		return msg.Stack != ""
+	}}
+}
+
+// TestOutputter implements logging.Outputter by writing messages to a test's log with t.Log, so that they are shown
+// alongside the test's failures.
+type TestOutputter struct {
+	T         testing.TB
+	Formatter logging.Formatter
+}
+
+// Returns a TestOutputter that formats messages as "[LEVEL] logger: msg".
+func NewTestOutputter(t testing.TB) TestOutputter {
+	return TestOutputter{t, logging.NewBasicFormatter("[$level] $logger: $msg")}
+}
+
+// Implements logging.Outputter.
+func (o TestOutputter) Output(msg *logging.Message) {
+	o.T.Log(o.Formatter.Format(msg))
+}
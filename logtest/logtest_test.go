@@ -0,0 +1,85 @@
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"sync"
+	"testing"
+)
+
+func TestCapture(t *testing.T) {
+	logger := logging.Get("logtest")
+	logger.SetThreshold(logging.Error)
+	parent := NewRecorder(t)
+	logging.Root.AddOutput(parent)
+	defer logging.Root.RemoveOutput(parent)
+
+	var recorder *Recorder
+	t.Run("propagating", func(t *testing.T) {
+		recorder = Capture(t, "logtest")
+		logger.Debug("propagated")
+		recorder.AssertLogged(logging.Debug, "propagated")
+		if n := len(parent.Messages()); n != 1 {
+			t.Errorf("expected the captured message to propagate, got %d messages", n)
+		}
+	})
+	parent.Reset()
+
+	t.Run("isolated", func(t *testing.T) {
+		recorder = Capture(t, "logtest").Isolate()
+		errNotFound := errors.New("not found")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				logger.Debugf("message %d", i)
+			}(i)
+		}
+		wg.Wait()
+		logger.Warn("lookup failed: ", fmt.Errorf("user 42: %w", errNotFound))
+
+		if n := len(recorder.Messages()); n != 11 {
+			t.Errorf("expected 11 messages, got %d", n)
+		}
+		recorder.AssertLogged(logging.Debug, "message 7")
+		recorder.AssertNotLogged(logging.Info, "message")
+		recorder.AssertMatch(AtLevel(logging.Warn), FromLogger("logtest"), WithError(errNotFound))
+		if n := len(parent.Messages()); n != 0 {
+			t.Errorf("captured messages were propagated: %d", n)
+		}
+	})
+
+	if logger.Threshold() != logging.Error || logger.NoPropagate() {
+		t.Errorf("logger settings were not restored")
+	}
+	logger.Error("not captured")
+	if len(recorder.Messages()) != 11 || len(parent.Messages()) != 1 {
+		t.Errorf("message was captured after the test finished")
+	}
+}
+
+// Records calls to Log, and fails the test for any other method of testing.TB that is not implemented.
+type fakeTB struct {
+	testing.TB
+	logs []string
+}
+
+func (f *fakeTB) Log(args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func TestTestOutputter(t *testing.T) {
+	h := logging.NewHierarchy()
+	fake := &fakeTB{TB: t}
+	logger := h.Get("app.db")
+	logger.SetThreshold(logging.Info)
+	logger.AddOutput(NewTestOutputter(fake))
+	logger.Warn("slow query")
+	logger.Debug("not logged")
+	if len(fake.logs) != 1 || fake.logs[0] != "[WARN] app.db: slow query" {
+		t.Errorf("unexpected logs: %q", fake.logs)
+	}
+}
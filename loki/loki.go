@@ -0,0 +1,287 @@
+// Package loki provides a Grafana Loki plugin for go-logging, which pushes messages to Loki's push API.
+//
+// Importing the package registers the "loki" output type:
+//
+//	[loki]
+//	type = loki
+//	url = http://loki:3100/loki/api/v1/push
+//	labels = app=payments, env=prod
+//	label_fields = logger, level
+//	tenant = team-a
+//
+// Messages are grouped into streams by their labels, which are made of the static labels along with the label fields
+// of each message: "logger", "level" and "file" are supported. Messages are sent in batches by a
+// logging.BatchOutputter, so the options accepted by logging.ParseBatchOptions can be used too.
+package loki
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/vaughan0/go-logging"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The push URL used when none is configured.
+const DefaultURL = "http://localhost:3100/loki/api/v1/push"
+
+// The supported protocols.
+const (
+	ProtocolProtobuf = "protobuf"
+	ProtocolJSON     = "json"
+)
+
+// The label fields used when none are configured.
+var DefaultLabelFields = []string{"logger", "level"}
+
+var labelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Pusher implements logging.BatchSender by pushing messages to Loki.
+type Pusher struct {
+	// The URL of Loki's push API. Defaults to DefaultURL.
+	URL string
+	// Either ProtocolProtobuf (the default), which is compressed with snappy, or ProtocolJSON.
+	Protocol string
+	// Labels that are added to every stream.
+	Labels map[string]string
+	// The message fields that are used as labels: "logger", "level" or "file". Defaults to DefaultLabelFields.
+	LabelFields []string
+	// Formats the line of each entry.
+	Formatter logging.Formatter
+	// The tenant, which is sent in the X-Scope-OrgID header if it is set.
+	Tenant string
+	// Extra headers to send with each request, such as for authentication.
+	Headers map[string]string
+	// The client used to send requests. Defaults to logging.DefaultHTTPClient.
+	Client *http.Client
+}
+
+// Creates a logging.BatchOutputter that pushes messages with the Pusher.
+func NewOutputter(p *Pusher, options logging.BatchOptions) *logging.BatchOutputter {
+	return logging.NewBatchOutputter(p, options)
+}
+
+type stream struct {
+	labels  map[string]string
+	key     string
+	entries []*logging.Message
+}
+
+// Groups messages into streams, in the order that each stream first appears.
+func (p *Pusher) streams(msgs []*logging.Message) []*stream {
+	fields := p.LabelFields
+	if fields == nil {
+		fields = DefaultLabelFields
+	}
+	var result []*stream
+	byKey := make(map[string]*stream)
+	for _, msg := range msgs {
+		labels := make(map[string]string, len(p.Labels)+len(fields))
+		for key, value := range p.Labels {
+			labels[key] = value
+		}
+		for _, field := range fields {
+			switch field {
+			case "logger":
+				if msg.Logger != nil {
+					labels["logger"] = msg.Logger.Name
+				}
+			case "level":
+				labels["level"] = strings.ToLower(msg.Level.String())
+			case "file":
+				labels["file"] = path.Base(msg.File)
+			}
+		}
+		key := formatLabels(labels)
+		s := byKey[key]
+		if s == nil {
+			s = &stream{labels: labels, key: key}
+			byKey[key] = s
+			result = append(result, s)
+		}
+		s.entries = append(s.entries, msg)
+	}
+	return result
+}
+
+// Formats labels in the form {name="value", ...}, which is how Loki identifies a stream.
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + "=" + strconv.Quote(labels[name])
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func (p *Pusher) line(msg *logging.Message) string {
+	return strings.TrimRight(p.Formatter.Format(msg), "\n")
+}
+
+// Encodes a push request as JSON.
+func (p *Pusher) encodeJSON(streams []*stream) ([]byte, error) {
+	type jsonStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var body struct {
+		Streams []jsonStream `json:"streams"`
+	}
+	for _, s := range streams {
+		js := jsonStream{Stream: s.labels}
+		for _, msg := range s.entries {
+			js.Values = append(js.Values, [2]string{strconv.FormatInt(msg.Time.UnixNano(), 10), p.line(msg)})
+		}
+		body.Streams = append(body.Streams, js)
+	}
+	return json.Marshal(body)
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = binary.AppendUvarint(appendTag(b, field, 2), uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendTag(b, field, 0), v)
+}
+
+// Encodes a push request as protobuf (see logproto.PushRequest in the Loki repository).
+func (p *Pusher) encodeProtobuf(streams []*stream) []byte {
+	var request []byte
+	for _, s := range streams {
+		adapter := appendBytesField(nil, 1, []byte(s.key))
+		for _, msg := range s.entries {
+			var timestamp []byte
+			timestamp = appendVarintField(timestamp, 1, uint64(msg.Time.Unix()))
+			timestamp = appendVarintField(timestamp, 2, uint64(msg.Time.Nanosecond()))
+			entry := appendBytesField(nil, 1, timestamp)
+			entry = appendBytesField(entry, 2, []byte(p.line(msg)))
+			adapter = appendBytesField(adapter, 2, entry)
+		}
+		request = appendBytesField(request, 1, adapter)
+	}
+	return request
+}
+
+// Implements logging.BatchSender. Responses with a status of 429 (Too Many Requests) or 5xx are retried, after the delay
+// in the Retry-After header if there is one, while other failures are returned as logging.PermanentError.
+func (p *Pusher) SendBatch(msgs []*logging.Message) error {
+	streams := p.streams(msgs)
+	var body []byte
+	var contentType string
+	switch p.Protocol {
+	case "", ProtocolProtobuf:
+		body = snappyEncode(p.encodeProtobuf(streams))
+		contentType = "application/x-protobuf"
+	case ProtocolJSON:
+		var err error
+		if body, err = p.encodeJSON(streams); err != nil {
+			return logging.PermanentError{Err: err}
+		}
+		contentType = "application/json"
+	default:
+		return logging.PermanentError{Err: errors.New("unknown loki protocol: " + p.Protocol)}
+	}
+
+	url := p.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return logging.PermanentError{Err: err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	if p.Tenant != "" {
+		req.Header.Set("X-Scope-OrgID", p.Tenant)
+	}
+	for key, value := range p.Headers {
+		req.Header.Set(key, value)
+	}
+	return logging.SendHTTPBatch(p.Client, req, "loki push")
+}
+
+var lokiPlugin = logging.OutputPluginFunc(func(options map[string]string) (logging.Outputter, error) {
+	p := &Pusher{
+		URL:      options["url"],
+		Protocol: options["protocol"],
+		Tenant:   options["tenant"],
+	}
+	if p.Protocol != "" && p.Protocol != ProtocolProtobuf && p.Protocol != ProtocolJSON {
+		return nil, errors.New("unknown loki protocol: " + p.Protocol)
+	}
+	var err error
+	if p.Labels, err = logging.ParseKeyValues(options["labels"]); err != nil {
+		return nil, err
+	}
+	for name := range p.Labels {
+		if !labelName.MatchString(name) {
+			return nil, errors.New("invalid loki label name: " + name)
+		}
+	}
+	if fields, ok := options["label_fields"]; ok {
+		p.LabelFields = []string{}
+		for _, field := range strings.Split(fields, ",") {
+			switch field = strings.TrimSpace(field); field {
+			case "":
+			case "logger", "level", "file":
+				p.LabelFields = append(p.LabelFields, field)
+			default:
+				return nil, errors.New("unknown loki label field: " + field)
+			}
+		}
+	}
+	if p.Headers, err = logging.ParseKeyValues(options["headers"]); err != nil {
+		return nil, err
+	}
+	if username := options["username"]; username != "" {
+		p.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+options["password"]))
+	}
+
+	// Lines are formatted with the template "$msg" unless another format is chosen
+	formatOptions := options
+	if options["formatter"] == "" && options["format"] == "" {
+		formatOptions = map[string]string{"format": "$msg"}
+	}
+	if p.Formatter, err = logging.CreateFormatter(formatOptions); err != nil {
+		return nil, err
+	}
+	if timeout, ok := options["timeout"]; ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.New("invalid timeout: " + timeout)
+		}
+		p.Client = &http.Client{Timeout: d}
+	}
+
+	batch, err := logging.ParseBatchOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return NewOutputter(p, batch), nil
+})
+
+func init() {
+	logging.RegisterOutputPlugin("loki", lokiPlugin)
+	options := []string{"formatter", "url", "protocol", "labels", "label_fields", "tenant", "headers", "username",
+		"password", "timeout"}
+	logging.RegisterPluginOptions("loki", append(options, logging.BatchPluginOptions...)...)
+	logging.RegisterSecretOptions("loki", "headers", "username", "password")
+}
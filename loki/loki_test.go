@@ -0,0 +1,250 @@
+package loki
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/vaughan0/go-logging"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Decodes the snappy block format.
+func snappyDecode(t *testing.T, src []byte) []byte {
+	length, n := binary.Uvarint(src)
+	src = src[n:]
+	dst := make([]byte, 0, length)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 3 {
+		case 0:
+			size := int(tag >> 2)
+			src = src[1:]
+			if size >= 60 {
+				extra := size - 59
+				size = 0
+				for i := 0; i < extra; i++ {
+					size |= int(src[i]) << (8 * i)
+				}
+				src = src[extra:]
+			}
+			dst = append(dst, src[:size+1]...)
+			src = src[size+1:]
+			continue
+		case 1:
+			size := int(tag>>2&7) + 4
+			offset := int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+			for i := 0; i < size; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		case 2:
+			size := int(tag>>2) + 1
+			offset := int(src[1]) | int(src[2])<<8
+			src = src[3:]
+			for i := 0; i < size; i++ {
+				dst = append(dst, dst[len(dst)-offset])
+			}
+		default:
+			t.Fatal("unexpected copy with a 4-byte offset")
+		}
+	}
+	if uint64(len(dst)) != length {
+		t.Fatalf("decoded %d bytes, expected %d", len(dst), length)
+	}
+	return dst
+}
+
+func TestSnappy(t *testing.T) {
+	inputs := []string{
+		"",
+		"abc",
+		strings.Repeat("a", 1000),
+		strings.Repeat("level=info msg=\"request handled\" status=200 ", 500),
+		strings.Repeat("x", 70) + "unique" + strings.Repeat("0123456789", 300),
+	}
+	for _, input := range inputs {
+		encoded := snappyEncode([]byte(input))
+		if output := string(snappyDecode(t, encoded)); output != input {
+			t.Errorf("round trip failed for %.20q", input)
+		}
+		if len(input) > 1000 && len(encoded) > len(input)/4 {
+			t.Errorf("poor compression: %d bytes from %d", len(encoded), len(input))
+		}
+	}
+}
+
+type field struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+func decode(data []byte) (fields []field) {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		f := field{num: int(tag >> 3)}
+		if tag&7 == 0 {
+			f.value, n = binary.Uvarint(data)
+			data = data[n:]
+		} else {
+			length, n := binary.Uvarint(data)
+			f.data = data[n : n+int(length)]
+			data = data[n+int(length):]
+		}
+		fields = append(fields, f)
+	}
+	return
+}
+
+type server struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func newServer(statuses ...int) *server {
+	s := &server{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		status := http.StatusNoContent
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		w.WriteHeader(status)
+	}))
+	return s
+}
+
+func testMessages() []*logging.Message {
+	h := logging.NewHierarchy()
+	now := time.Unix(1700000000, 123)
+	return []*logging.Message{
+		{Level: logging.Info, Msg: "one", Logger: h.Get("a"), Time: now},
+		{Level: logging.Warn, Msg: "two", Logger: h.Get("a"), Time: now},
+		{Level: logging.Info, Msg: "three", Logger: h.Get("a"), Time: now},
+	}
+}
+
+func TestPushJSON(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	p := &Pusher{
+		URL:       s.URL + "/loki/api/v1/push",
+		Protocol:  ProtocolJSON,
+		Labels:    map[string]string{"app": "test"},
+		Formatter: logging.NewBasicFormatter("$level $msg"),
+		Tenant:    "team",
+	}
+	if err := p.SendBatch(testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	if req := s.requests[0]; req.URL.Path != "/loki/api/v1/push" || req.Header.Get("X-Scope-OrgID") != "team" {
+		t.Errorf("unexpected request: %s %v", req.URL, req.Header)
+	}
+	var body struct {
+		Streams []struct {
+			Stream map[string]string
+			Values [][2]string
+		}
+	}
+	if err := json.Unmarshal(s.bodies[0], &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Streams) != 2 {
+		t.Fatalf("expected 2 streams, got %s", s.bodies[0])
+	}
+	first := body.Streams[0]
+	if first.Stream["app"] != "test" || first.Stream["logger"] != "a" || first.Stream["level"] != "info" || len(first.Values) != 2 {
+		t.Errorf("unexpected stream: %+v", first)
+	}
+	if first.Values[1] != [2]string{"1700000000000000123", "INFO three"} {
+		t.Errorf("unexpected entry: %v", first.Values[1])
+	}
+}
+
+func TestPushProtobuf(t *testing.T) {
+	s := newServer()
+	defer s.Close()
+	p := &Pusher{URL: s.URL, Formatter: logging.NewBasicFormatter("$msg"), LabelFields: []string{"logger"}}
+	if err := p.SendBatch(testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	if s.requests[0].Header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("unexpected content type: %s", s.requests[0].Header.Get("Content-Type"))
+	}
+	streams := decode(snappyDecode(t, s.bodies[0]))
+	if len(streams) != 1 {
+		t.Fatalf("expected 1 stream, got %d", len(streams))
+	}
+	adapter := decode(streams[0].data)
+	if string(adapter[0].data) != `{logger="a"}` || len(adapter) != 4 {
+		t.Errorf("unexpected stream: %q with %d fields", adapter[0].data, len(adapter))
+	}
+	entry := decode(adapter[3].data)
+	timestamp := decode(entry[0].data)
+	if timestamp[0].value != 1700000000 || timestamp[1].value != 123 || string(entry[1].data) != "three" {
+		t.Errorf("unexpected entry: %v %q", timestamp, entry[1].data)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	s := newServer(http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusNoContent, http.StatusBadRequest)
+	defer s.Close()
+	h := logging.NewHierarchy()
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, loki
+
+  [loki]
+  type = loki
+  url = ` + s.URL + `
+  protocol = json
+  labels = app=payments
+  label_fields = level
+  username = user
+  password = pass
+  batch_interval = 1h
+  retry_backoff = 1ms
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Get("a").Info("one")
+	h.Flush()
+	if len(s.bodies) != 3 {
+		t.Fatalf("expected two retries, got %d requests", len(s.bodies))
+	}
+	if user, pass, _ := s.requests[2].BasicAuth(); user != "user" || pass != "pass" {
+		t.Error("basic auth not sent")
+	}
+	if body := string(s.bodies[2]); !strings.Contains(body, `"stream":{"app":"payments","level":"info"}`) {
+		t.Errorf("unexpected body: %s", body)
+	}
+
+	// Client errors are not retried
+	h.Get("a").Info("two")
+	h.Flush()
+	if len(s.bodies) != 4 {
+		t.Errorf("client error was retried: %d requests", len(s.bodies))
+	}
+
+	err = h.SetupReader(strings.NewReader("[loggers]\nroot = INFO, loki\n[loki]\ntype = loki\nlabel_fields = host\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown loki label field") {
+		t.Errorf("unexpected error for unknown label field: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package loki
+
+import (
+	"encoding/binary"
+)
+
+// A small encoder for the snappy block format, which Loki requires for protobuf push requests. It finds matches with a
+// hash table of 4-byte sequences, which compresses typical log lines well without the complexity of the reference
+// implementation.
+
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+	const tableBits = 14
+	var table [1 << tableBits]int32
+	for i := range table {
+		table[i] = -1
+	}
+	hash := func(v uint32) uint32 {
+		return (v * 0x1e35a7bd) >> (32 - tableBits)
+	}
+
+	literal := 0
+	for i := 0; i+4 <= len(src); {
+		v := binary.LittleEndian.Uint32(src[i:])
+		h := hash(v)
+		candidate := int(table[h])
+		table[h] = int32(i)
+		if candidate < 0 || i-candidate > 0xffff || binary.LittleEndian.Uint32(src[candidate:]) != v {
+			i++
+			continue
+		}
+		dst = appendLiteral(dst, src[literal:i])
+		length := 4
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = appendCopy(dst, i-candidate, length)
+		i += length
+		literal = i
+	}
+	return appendLiteral(dst, src[literal:])
+}
+
+func appendLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2)
+	case n < 1<<8:
+		dst = append(dst, 60<<2, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+func appendCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = append(dst, 63<<2|2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|1, byte(offset))
+}
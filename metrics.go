@@ -0,0 +1,278 @@
+package logging
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// A MetricsSink receives logging pipeline events as they happen. It can be used to feed an external metrics system
+// (such as Prometheus) without this package depending on it. Sinks are called synchronously, and must be safe for
+// concurrent use.
+type MetricsSink interface {
+	// Called when a message meets its Logger's threshold and is sent to the Logger's outputs.
+	MessageLogged(logger string, level Level)
+	// Called when an output reports an error.
+	OutputError(output string)
+	// Called after each message has been sent to an output.
+	OutputLatency(output string, duration time.Duration)
+	// Called when an output that queues messages, such as a BatchOutputter, discards them because its queue is full or
+	// because they could not be sent.
+	OutputDropped(output string, count int)
+}
+
+// The upper bounds of the buckets used by latency histograms. Durations greater than the last bound are counted in an
+// extra overflow bucket.
+var LatencyBuckets = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// A Histogram counts durations by bucket.
+type Histogram struct {
+	// The upper bounds of each bucket (see LatencyBuckets).
+	Buckets []time.Duration
+	// The number of durations in each bucket. Counts has one more element than Buckets, which counts durations that are
+	// greater than every bound.
+	Counts []uint64
+	// The total number of durations.
+	Count uint64
+	// The sum of all durations.
+	Sum time.Duration
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{
+		Buckets: LatencyBuckets,
+		Counts:  make([]uint64, len(LatencyBuckets)+1),
+	}
+}
+
+// Records a duration. It is safe to call concurrently with observe and copy.
+func (h *Histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(h.Buckets) && d > h.Buckets[i] {
+		i++
+	}
+	atomic.AddUint64(&h.Counts[i], 1)
+	atomic.AddUint64(&h.Count, 1)
+	atomic.AddInt64((*int64)(&h.Sum), int64(d))
+}
+
+func (h *Histogram) copy() Histogram {
+	result := Histogram{
+		Buckets: h.Buckets,
+		Counts:  make([]uint64, len(h.Counts)),
+		Count:   atomic.LoadUint64(&h.Count),
+		Sum:     time.Duration(atomic.LoadInt64((*int64)(&h.Sum))),
+	}
+	for i := range h.Counts {
+		result.Counts[i] = atomic.LoadUint64(&h.Counts[i])
+	}
+	return result
+}
+
+// Metrics is a snapshot of the logging pipeline's counters.
+type Metrics struct {
+	// The number of messages logged by each Logger, keyed by logger name and then by level.
+	Messages map[string]map[Level]uint64
+	// The number of messages that were discarded by each Logger's threshold, keyed by logger name.
+	Filtered map[string]uint64
+	// The number of failed writes for each output, as returned by OutputErrors.
+	OutputErrors map[string]uint64
+	// The time taken to output each message, keyed by output name.
+	OutputLatency map[string]Histogram
+	// The number of messages waiting to be sent by each output of the hierarchy's configuration that queues messages
+	// (such as a BatchOutputter), keyed by output name.
+	Queues map[string]int
+	// The number of messages discarded by the same outputs, keyed by output name.
+	Dropped map[string]uint64
+}
+
+// Guards otherCounts and the creation of latency histograms. Messages with the standard levels are counted without it.
+var metricsLock sync.Mutex
+var otherCounts = make(map[*Logger]map[Level]uint64)
+
+// The latency histogram of each named output, which are shared by all outputs with the same name.
+var outputLatency sync.Map
+
+// The registered sinks, as a []MetricsSink.
+var metricsSinks atomic.Value
+
+// Adds a MetricsSink, which will receive all subsequent logging pipeline events.
+func AddMetricsSink(sink MetricsSink) {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	sinks := getSinks()
+	metricsSinks.Store(append(sinks[:len(sinks):len(sinks)], sink))
+}
+
+func getSinks() []MetricsSink {
+	sinks, _ := metricsSinks.Load().([]MetricsSink)
+	return sinks
+}
+
+// Returns the index of a standard level in Logger.logged, or -1 for other levels.
+func levelIndex(level Level) int {
+	if level > Fatal || level < Trace || (int(level)+1)%100 != 0 {
+		return -1
+	}
+	return -(int(level)+1)/100 - 1
+}
+
+var standardLevels = []Level{Fatal, Error, Warn, Notice, Info, Debug, Trace}
+
+func recordLogged(l *Logger, level Level) {
+	if i := levelIndex(level); i >= 0 {
+		atomic.AddUint64(&l.logged[i], 1)
+	} else {
+		metricsLock.Lock()
+		counts := otherCounts[l]
+		if counts == nil {
+			counts = make(map[Level]uint64)
+			otherCounts[l] = counts
+		}
+		counts[level]++
+		metricsLock.Unlock()
+	}
+
+	for _, sink := range getSinks() {
+		sink.MessageLogged(l.Name, level)
+	}
+}
+
+// Returns the latency histogram of a named output, creating it if needed.
+func latencyHistogram(name string) *Histogram {
+	if hist, ok := outputLatency.Load(name); ok {
+		return hist.(*Histogram)
+	}
+	hist, _ := outputLatency.LoadOrStore(name, newHistogram())
+	return hist.(*Histogram)
+}
+
+// Returns the name and latency histogram of an output, if it is named (see NamedOutputter). Latencies are only recorded
+// for named outputs.
+func outputMetrics(o Outputter) (string, *Histogram) {
+	if thresh, ok := o.(ThresholdOutputter); ok {
+		o = thresh.Outputter
+	}
+	named, ok := o.(NamedOutputter)
+	if !ok {
+		return "", nil
+	}
+	if named.latency == nil {
+		return named.Name, latencyHistogram(named.Name)
+	}
+	return named.Name, named.latency
+}
+
+func recordLatency(name string, hist *Histogram, d time.Duration) {
+	hist.observe(d)
+	for _, sink := range getSinks() {
+		sink.OutputLatency(name, d)
+	}
+}
+
+func recordDropped(name string, count int) {
+	for _, sink := range getSinks() {
+		sink.OutputDropped(name, count)
+	}
+}
+
+// Implemented by outputs that queue messages, such as BatchOutputter.
+type queuedOutputter interface {
+	QueueLen() int
+	Dropped() uint64
+}
+
+// Returns a snapshot of the logging pipeline's counters. Message counts only include the hierarchy's loggers, while
+// output errors and latencies are shared by all hierarchies.
+func (h *Hierarchy) Stats() Metrics {
+	stats := Metrics{
+		Messages:      make(map[string]map[Level]uint64),
+		Filtered:      make(map[string]uint64),
+		OutputErrors:  OutputErrors(),
+		OutputLatency: make(map[string]Histogram),
+		Queues:        make(map[string]int),
+		Dropped:       make(map[string]uint64),
+	}
+
+	h.lock.Lock()
+	all := []*Logger{h.Root}
+	for _, logger := range h.loggers {
+		all = append(all, logger)
+	}
+	for name, output := range h.outputters {
+		if queued, ok := baseOutputter(output).(queuedOutputter); ok {
+			stats.Queues[name] = queued.QueueLen()
+			stats.Dropped[name] = queued.Dropped()
+		}
+	}
+	h.lock.Unlock()
+	for _, logger := range all {
+		if filtered := atomic.LoadUint64(&logger.filtered); filtered > 0 {
+			stats.Filtered[logger.Name] = filtered
+		}
+	}
+
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+	for _, logger := range all {
+		var levels map[Level]uint64
+		for i, level := range standardLevels {
+			if count := atomic.LoadUint64(&logger.logged[i]); count > 0 {
+				if levels == nil {
+					levels = make(map[Level]uint64)
+				}
+				levels[level] = count
+			}
+		}
+		for level, count := range otherCounts[logger] {
+			if levels == nil {
+				levels = make(map[Level]uint64)
+			}
+			levels[level] = count
+		}
+		if levels != nil {
+			stats.Messages[logger.Name] = levels
+		}
+	}
+	outputLatency.Range(func(name, hist interface{}) bool {
+		stats.OutputLatency[name.(string)] = hist.(*Histogram).copy()
+		return true
+	})
+	return stats
+}
+
+// Returns a snapshot of the Default hierarchy's counters (see Hierarchy.Stats).
+func Stats() Metrics {
+	return Default.Stats()
+}
+
+// Publishes the result of Stats as an expvar variable with the given name. Message counts are keyed by level name.
+func PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		stats := Stats()
+		messages := make(map[string]map[string]uint64, len(stats.Messages))
+		for logger, counts := range stats.Messages {
+			levels := make(map[string]uint64, len(counts))
+			for level, count := range counts {
+				levels[level.String()] = count
+			}
+			messages[logger] = levels
+		}
+		return map[string]interface{}{
+			"messages":      messages,
+			"filtered":      stats.Filtered,
+			"outputErrors":  stats.OutputErrors,
+			"outputLatency": stats.OutputLatency,
+			"queues":        stats.Queues,
+			"dropped":       stats.Dropped,
+		}
+	}))
+}
@@ -0,0 +1,100 @@
+package logging
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	resetLoggers()
+	defer resetLoggers()
+	var logs msgSlice
+	logger := Get("metrics")
+	logger.SetThreshold(Info)
+	logger.AddOutput(NewNamedOutputter("mock", &logs))
+
+	before := Stats()
+	logger.Info("one")
+	logger.Warn("two")
+	logger.Warn("three")
+	logger.Debug("filtered")
+	after := Stats()
+
+	if n := after.Messages["metrics"][Info] - before.Messages["metrics"][Info]; n != 1 {
+		t.Errorf("expected 1 INFO message, got %d", n)
+	}
+	if n := after.Messages["metrics"][Warn] - before.Messages["metrics"][Warn]; n != 2 {
+		t.Errorf("expected 2 WARN messages, got %d", n)
+	}
+	if n := after.Filtered["metrics"] - before.Filtered["metrics"]; n != 1 {
+		t.Errorf("expected 1 filtered message, got %d", n)
+	}
+	if n := after.OutputLatency["mock"].Count - before.OutputLatency["mock"].Count; n != 3 {
+		t.Errorf("expected 3 latency observations, got %d", n)
+	}
+}
+
+func TestStatsConcurrent(t *testing.T) {
+	h := NewHierarchy()
+	var logs msgSlice
+	var lock sync.Mutex
+	logger := h.Get("concurrent")
+	logger.SetThreshold(Trace)
+	logger.AddOutput(NamedOutputter{Name: "concurrent", Outputter: OutputterFunc(func(msg *Message) {
+		lock.Lock()
+		defer lock.Unlock()
+		logs = append(logs, msg)
+	})})
+	before := h.Stats().OutputLatency["concurrent"].Count
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				logger.Info("info")
+				logger.Log(Info+50, "custom")
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := h.Stats()
+	if n := stats.Messages["concurrent"][Info]; n != 800 {
+		t.Errorf("expected 800 INFO messages, got %d", n)
+	}
+	if n := stats.Messages["concurrent"][Info+50]; n != 800 {
+		t.Errorf("expected 800 messages with a custom level, got %d", n)
+	}
+	hist := stats.OutputLatency["concurrent"]
+	if n := hist.Count - before; n != 1600 {
+		t.Errorf("expected 1600 latency observations, got %d", n)
+	}
+	var total uint64
+	for _, count := range hist.Counts {
+		total += count
+	}
+	if total != hist.Count {
+		t.Errorf("bucket counts add up to %d, expected %d", total, hist.Count)
+	}
+}
+
+func TestLatencyAttachmentThreshold(t *testing.T) {
+	h := NewHierarchy()
+	var logs msgSlice
+	logger := h.Get("attached")
+	logger.SetThreshold(Trace)
+	logger.AddOutputThreshold(NewNamedOutputter("attached", &logs), Warn)
+	before := h.Stats().OutputLatency["attached"].Count
+
+	logger.Info("below the attachment's threshold")
+	logger.Warn("sent")
+
+	if n := h.Stats().OutputLatency["attached"].Count - before; n != 1 {
+		t.Errorf("expected 1 latency observation, got %d", n)
+	}
+	if len(logs) != 1 {
+		t.Errorf("expected 1 message, got %d", len(logs))
+	}
+}
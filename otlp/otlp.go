@@ -0,0 +1,326 @@
+// Package otlp provides an OpenTelemetry plugin for go-logging, which exports messages as OTLP log records over HTTP.
+//
+// Importing the package registers the "otlp" output type:
+//
+//	[otel]
+//	type = otlp
+//	endpoint = http://collector:4318/v1/logs
+//	protocol = http/protobuf
+//	service_name = payments
+//	resource = deployment.environment=prod, service.version=1.2
+//	headers = Authorization=Bearer secret
+//
+// Messages are sent in batches by a logging.BatchOutputter, so the options accepted by logging.ParseBatchOptions can
+// be used too. Each logger becomes an instrumentation scope, and messages logged with a context (see
+// logging.Logger.WithContext) are correlated with the span in the context (see SpanFromContext).
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/vaughan0/go-logging"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// The endpoint used when none is configured, which is the default for an OpenTelemetry collector.
+const DefaultEndpoint = "http://localhost:4318/v1/logs"
+
+// The supported protocols.
+const (
+	ProtocolProtobuf = "http/protobuf"
+	ProtocolJSON     = "http/json"
+)
+
+// A TraceID identifies a trace. It has the same layout as the TraceID type of the OpenTelemetry API.
+type TraceID [16]byte
+
+// A SpanID identifies a span. It has the same layout as the SpanID type of the OpenTelemetry API.
+type SpanID [8]byte
+
+type spanKey struct{}
+
+type span struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+// Returns a copy of the context that carries the given span, which is read by the default SpanFromContext.
+func ContextWithSpan(ctx context.Context, traceID TraceID, spanID SpanID) context.Context {
+	return context.WithValue(ctx, spanKey{}, span{traceID, spanID})
+}
+
+// Returns the span that a message's context belongs to, if any. By default, it returns the span stored by
+// ContextWithSpan. Programs that use the OpenTelemetry API can replace it to correlate messages with their spans:
+//
+//	otlp.SpanFromContext = func(ctx context.Context) (otlp.TraceID, otlp.SpanID, bool) {
+//		sc := trace.SpanContextFromContext(ctx)
+//		return otlp.TraceID(sc.TraceID()), otlp.SpanID(sc.SpanID()), sc.IsValid()
+//	}
+var SpanFromContext = func(ctx context.Context) (traceID TraceID, spanID SpanID, ok bool) {
+	s, ok := ctx.Value(spanKey{}).(span)
+	return s.traceID, s.spanID, ok
+}
+
+// Returns the OpenTelemetry SeverityNumber for a level.
+func Severity(level logging.Level) int {
+	switch level {
+	case logging.Trace:
+		return 1
+	case logging.Debug:
+		return 5
+	case logging.Info:
+		return 9
+	case logging.Notice:
+		return 10
+	case logging.Warn:
+		return 13
+	case logging.Error:
+		return 17
+	case logging.Fatal:
+		return 21
+	}
+	return 0
+}
+
+// Exporter implements logging.BatchSender by sending messages to an OTLP/HTTP endpoint.
+type Exporter struct {
+	// The URL that log records are posted to. Defaults to DefaultEndpoint.
+	Endpoint string
+	// Either ProtocolProtobuf (the default) or ProtocolJSON.
+	Protocol string
+	// Extra headers to send with each request, such as for authentication.
+	Headers map[string]string
+	// The attributes of the resource that produced the messages, such as "service.name".
+	Resource map[string]string
+	// The client used to send requests. Defaults to logging.DefaultHTTPClient.
+	Client *http.Client
+}
+
+// Creates a logging.BatchOutputter that sends messages with the Exporter.
+func NewOutputter(e *Exporter, options logging.BatchOptions) *logging.BatchOutputter {
+	return logging.NewBatchOutputter(e, options)
+}
+
+// Implements logging.BatchSender. Responses with a status of 429 (Too Many Requests) or 5xx are retried, after the delay
+// in the Retry-After header if there is one, while other failures are returned as logging.PermanentError.
+func (e *Exporter) SendBatch(msgs []*logging.Message) error {
+	req := e.newRequest(msgs)
+	var body []byte
+	contentType := "application/x-protobuf"
+	switch e.Protocol {
+	case "", ProtocolProtobuf:
+		body = encodeRequest(req)
+	case ProtocolJSON:
+		contentType = "application/json"
+		var err error
+		if body, err = json.Marshal(jsonRequest(req)); err != nil {
+			return logging.PermanentError{Err: err}
+		}
+	default:
+		return logging.PermanentError{Err: errors.New("unknown otlp protocol: " + e.Protocol)}
+	}
+
+	endpoint := e.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return logging.PermanentError{Err: err}
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	for key, value := range e.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	return logging.SendHTTPBatch(e.Client, httpReq, "otlp export")
+}
+
+type attribute struct {
+	Key   string
+	Value interface{}
+}
+
+type record struct {
+	Time, ObservedTime uint64
+	Severity           int
+	SeverityText       string
+	Body               string
+	Attributes         []attribute
+	HasSpan            bool
+	TraceID            TraceID
+	SpanID             SpanID
+}
+
+type scope struct {
+	Name    string
+	Records []*record
+}
+
+type request struct {
+	Resource []attribute
+	Scopes   []*scope
+}
+
+// Converts messages to log records, grouped into a scope for each logger.
+func (e *Exporter) newRequest(msgs []*logging.Message) *request {
+	req := &request{}
+	keys := make([]string, 0, len(e.Resource))
+	for key := range e.Resource {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		req.Resource = append(req.Resource, attribute{key, e.Resource[key]})
+	}
+
+	scopes := make(map[string]*scope)
+	observed := uint64(time.Now().UnixNano())
+	for _, msg := range msgs {
+		name := ""
+		if msg.Logger != nil {
+			name = msg.Logger.Name
+		}
+		s := scopes[name]
+		if s == nil {
+			s = &scope{Name: name}
+			scopes[name] = s
+			req.Scopes = append(req.Scopes, s)
+		}
+		s.Records = append(s.Records, newRecord(msg, observed))
+	}
+	return req
+}
+
+func newRecord(msg *logging.Message, observed uint64) *record {
+	r := &record{
+		Time:         uint64(msg.Time.UnixNano()),
+		ObservedTime: observed,
+		Severity:     Severity(msg.Level),
+		SeverityText: msg.Level.String(),
+		Body:         msg.Msg,
+	}
+	if msg.File != "" {
+		r.Attributes = append(r.Attributes,
+			attribute{"code.filepath", path.Base(msg.File)},
+			attribute{"code.lineno", int64(msg.Line)},
+		)
+	}
+	if len(msg.Errors) > 0 {
+		r.Attributes = append(r.Attributes,
+			attribute{"exception.type", fmt.Sprintf("%T", msg.Errors[0])},
+			attribute{"exception.message", msg.Errors[0].Error()},
+		)
+	}
+	if msg.Stack != "" {
+		r.Attributes = append(r.Attributes, attribute{"exception.stacktrace", msg.Stack})
+	}
+	if msg.Traced {
+		r.Attributes = append(r.Attributes, attribute{"traced", true})
+	}
+	if msg.Context != nil {
+		r.TraceID, r.SpanID, r.HasSpan = SpanFromContext(msg.Context)
+	}
+	return r
+}
+
+// Converts a request to the OTLP/JSON encoding, where 64-bit integers are strings and IDs are hex strings.
+func jsonRequest(req *request) map[string]interface{} {
+	var scopeLogs []interface{}
+	for _, s := range req.Scopes {
+		var records []interface{}
+		for _, r := range s.Records {
+			record := map[string]interface{}{
+				"timeUnixNano":         strconv.FormatUint(r.Time, 10),
+				"observedTimeUnixNano": strconv.FormatUint(r.ObservedTime, 10),
+				"severityNumber":       r.Severity,
+				"severityText":         r.SeverityText,
+				"body":                 jsonValue(r.Body),
+				"attributes":           jsonAttributes(r.Attributes),
+			}
+			if r.HasSpan {
+				record["traceId"] = hex.EncodeToString(r.TraceID[:])
+				record["spanId"] = hex.EncodeToString(r.SpanID[:])
+			}
+			records = append(records, record)
+		}
+		scopeLogs = append(scopeLogs, map[string]interface{}{
+			"scope":      map[string]interface{}{"name": s.Name},
+			"logRecords": records,
+		})
+	}
+	return map[string]interface{}{
+		"resourceLogs": []interface{}{
+			map[string]interface{}{
+				"resource":  map[string]interface{}{"attributes": jsonAttributes(req.Resource)},
+				"scopeLogs": scopeLogs,
+			},
+		},
+	}
+}
+
+func jsonAttributes(attrs []attribute) []interface{} {
+	result := []interface{}{}
+	for _, attr := range attrs {
+		result = append(result, map[string]interface{}{"key": attr.Key, "value": jsonValue(attr.Value)})
+	}
+	return result
+}
+
+func jsonValue(value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	case int64:
+		return map[string]interface{}{"intValue": strconv.FormatInt(value, 10)}
+	case float64:
+		return map[string]interface{}{"doubleValue": value}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+}
+
+var otlpPlugin = logging.OutputPluginFunc(func(options map[string]string) (logging.Outputter, error) {
+	e := &Exporter{
+		Endpoint: options["endpoint"],
+		Protocol: options["protocol"],
+	}
+	if e.Protocol != "" && e.Protocol != ProtocolProtobuf && e.Protocol != ProtocolJSON {
+		return nil, errors.New("unknown otlp protocol: " + e.Protocol)
+	}
+	var err error
+	if e.Headers, err = logging.ParseKeyValues(options["headers"]); err != nil {
+		return nil, err
+	}
+	if e.Resource, err = logging.ParseKeyValues(options["resource"]); err != nil {
+		return nil, err
+	}
+	if name := options["service_name"]; name != "" {
+		e.Resource["service.name"] = name
+	}
+	if timeout, ok := options["timeout"]; ok {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errors.New("invalid timeout: " + timeout)
+		}
+		e.Client = &http.Client{Timeout: d}
+	}
+	batch, err := logging.ParseBatchOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return NewOutputter(e, batch), nil
+})
+
+func init() {
+	logging.RegisterOutputPlugin("otlp", otlpPlugin)
+	options := []string{"endpoint", "protocol", "headers", "resource", "service_name", "timeout"}
+	logging.RegisterPluginOptions("otlp", append(options, logging.BatchPluginOptions...)...)
+	logging.RegisterSecretOptions("otlp", "headers")
+}
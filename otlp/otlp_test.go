@@ -0,0 +1,241 @@
+package otlp
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/vaughan0/go-logging"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// A stand-in for an OpenTelemetry collector, which records the requests it receives.
+type collector struct {
+	*httptest.Server
+	lock     sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func newCollector(statuses ...int) *collector {
+	c := &collector{statuses: statuses}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.requests = append(c.requests, r)
+		c.bodies = append(c.bodies, body)
+		if len(c.statuses) > 0 {
+			w.WriteHeader(c.statuses[0])
+			c.statuses = c.statuses[1:]
+		}
+	}))
+	return c
+}
+
+func testMessages() []*logging.Message {
+	h := logging.NewHierarchy()
+	ctx := ContextWithSpan(context.Background(), TraceID{1, 2, 3}, SpanID{4, 5})
+	return []*logging.Message{
+		{Level: logging.Warn, Msg: "first", Logger: h.Get("a"), File: "/src/a.go", Line: 12, Context: ctx},
+		{Level: logging.Error, Msg: "second", Logger: h.Get("b"), Errors: []error{errors.New("oops")}},
+		{Level: logging.Debug, Msg: "third", Logger: h.Get("a"), Traced: true},
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	c := newCollector()
+	defer c.Close()
+	e := &Exporter{
+		Endpoint: c.URL + "/v1/logs",
+		Protocol: ProtocolJSON,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Resource: map[string]string{"service.name": "test"},
+	}
+	if err := e.SendBatch(testMessages()); err != nil {
+		t.Fatal(err)
+	}
+	req := c.requests[0]
+	if req.URL.Path != "/v1/logs" || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("unexpected request: %s %v", req.URL, req.Header)
+	}
+
+	var body struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value struct{ StringValue string }
+				}
+			}
+			ScopeLogs []struct {
+				Scope      struct{ Name string }
+				LogRecords []struct {
+					SeverityNumber int
+					SeverityText   string
+					Body           struct{ StringValue string }
+					TraceID        string `json:"traceId"`
+					SpanID         string `json:"spanId"`
+					Attributes     []struct {
+						Key   string
+						Value map[string]interface{}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(c.bodies[0], &body); err != nil {
+		t.Fatal(err)
+	}
+	rl := body.ResourceLogs[0]
+	if attr := rl.Resource.Attributes[0]; attr.Key != "service.name" || attr.Value.StringValue != "test" {
+		t.Errorf("unexpected resource: %+v", rl.Resource)
+	}
+	if len(rl.ScopeLogs) != 2 || rl.ScopeLogs[0].Scope.Name != "a" || len(rl.ScopeLogs[0].LogRecords) != 2 {
+		t.Fatalf("unexpected scopes: %+v", rl.ScopeLogs)
+	}
+	first := rl.ScopeLogs[0].LogRecords[0]
+	if first.SeverityNumber != 13 || first.SeverityText != "WARN" || first.Body.StringValue != "first" {
+		t.Errorf("unexpected record: %+v", first)
+	}
+	if first.TraceID != "01020300000000000000000000000000" || first.SpanID != "0405000000000000" {
+		t.Errorf("unexpected span: %s %s", first.TraceID, first.SpanID)
+	}
+	if first.Attributes[0].Key != "code.filepath" || first.Attributes[1].Value["intValue"] != "12" {
+		t.Errorf("unexpected attributes: %+v", first.Attributes)
+	}
+	if attrs := rl.ScopeLogs[1].LogRecords[0].Attributes; attrs[0].Key != "exception.type" || attrs[1].Value["stringValue"] != "oops" {
+		t.Errorf("unexpected error attributes: %+v", attrs)
+	}
+	if traced := rl.ScopeLogs[0].LogRecords[1]; traced.TraceID != "" || traced.Attributes[0].Value["boolValue"] != true {
+		t.Errorf("unexpected traced record: %+v", traced)
+	}
+}
+
+// A field decoded from the protobuf wire format.
+type field struct {
+	num   int
+	value uint64
+	data  []byte
+}
+
+func decode(t *testing.T, data []byte) (fields []field) {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		data = data[n:]
+		f := field{num: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.value, n = binary.Uvarint(data)
+			data = data[n:]
+		case wireFixed64:
+			f.value = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			f.data = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type in tag %d", tag)
+		}
+		fields = append(fields, f)
+	}
+	return
+}
+
+// Returns the fields with the given number.
+func get(fields []field, num int) (result []field) {
+	for _, f := range fields {
+		if f.num == num {
+			result = append(result, f)
+		}
+	}
+	return
+}
+
+func TestExportProtobuf(t *testing.T) {
+	c := newCollector()
+	defer c.Close()
+	e := &Exporter{Endpoint: c.URL, Resource: map[string]string{"service.name": "test"}}
+	msgs := testMessages()
+	if err := e.SendBatch(msgs); err != nil {
+		t.Fatal(err)
+	}
+	if c.requests[0].Header.Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("unexpected content type: %s", c.requests[0].Header.Get("Content-Type"))
+	}
+
+	resourceLogs := decode(t, get(decode(t, c.bodies[0]), 1)[0].data)
+	resource := decode(t, get(resourceLogs, 1)[0].data)
+	kv := decode(t, get(resource, 1)[0].data)
+	if string(get(kv, 1)[0].data) != "service.name" {
+		t.Errorf("unexpected resource attribute: %q", get(kv, 1)[0].data)
+	}
+	scopes := get(resourceLogs, 2)
+	if len(scopes) != 2 {
+		t.Fatalf("expected 2 scopes, got %d", len(scopes))
+	}
+	scopeLogs := decode(t, scopes[0].data)
+	if name := decode(t, get(scopeLogs, 1)[0].data); string(get(name, 1)[0].data) != "a" {
+		t.Errorf("unexpected scope name: %q", get(name, 1)[0].data)
+	}
+	record := decode(t, get(scopeLogs, 2)[0].data)
+	if get(record, 1)[0].value != uint64(msgs[0].Time.UnixNano()) || get(record, 2)[0].value != 13 {
+		t.Error("unexpected time or severity")
+	}
+	if string(get(record, 3)[0].data) != "WARN" || string(get(decode(t, get(record, 5)[0].data), 1)[0].data) != "first" {
+		t.Error("unexpected severity text or body")
+	}
+	if traceID := get(record, 9)[0].data; len(traceID) != 16 || traceID[0] != 1 || len(get(record, 10)[0].data) != 8 {
+		t.Errorf("unexpected span: %v", traceID)
+	}
+}
+
+func TestPlugin(t *testing.T) {
+	c := newCollector(http.StatusServiceUnavailable, http.StatusOK)
+	defer c.Close()
+	h := logging.NewHierarchy()
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, otel
+
+  [otel]
+  type = otlp
+  endpoint = ` + c.URL + `
+  protocol = http/json
+  service_name = payments
+  resource = deployment.environment=test
+  headers = X-Token=secret
+  batch_size = 10
+  batch_interval = 1h
+  retry_backoff = 1ms
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Get("a").Info("one")
+	h.Get("a").Info("two")
+	if err := h.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.bodies) != 2 || c.requests[1].Header.Get("X-Token") != "secret" {
+		t.Fatalf("expected a retried request, got %d", len(c.bodies))
+	}
+	body := string(c.bodies[1])
+	for _, expected := range []string{`"one"`, `"two"`, `"payments"`, `"deployment.environment"`} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("%s missing from request: %s", expected, body)
+		}
+	}
+
+	err = h.SetupReader(strings.NewReader("[loggers]\nroot = INFO, otel\n[otel]\ntype = otlp\nprotocol = grpc\n"))
+	if err == nil || !strings.Contains(err.Error(), "unknown otlp protocol") {
+		t.Errorf("unexpected error for unknown protocol: %v", err)
+	}
+}
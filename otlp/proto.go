@@ -0,0 +1,103 @@
+package otlp
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// A minimal encoder for the protobuf wire format, covering the parts of the OTLP logs protocol that are used by
+// Exporter. Field numbers are taken from opentelemetry/proto/logs/v1/logs.proto and common/v1/common.proto.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	return appendVarint(appendTag(b, field, wireVarint), v)
+}
+
+func appendFixed64Field(b []byte, field int, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(appendTag(b, field, wireFixed64), v)
+}
+
+func appendBytesField(b []byte, field int, data []byte) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(data)))
+	return append(b, data...)
+}
+
+func appendStringField(b []byte, field int, s string) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(s)))
+	return append(b, s...)
+}
+
+// Encodes an AnyValue message.
+func encodeValue(value interface{}) (b []byte) {
+	switch value := value.(type) {
+	case string:
+		b = appendStringField(b, 1, value)
+	case bool:
+		v := uint64(0)
+		if value {
+			v = 1
+		}
+		b = appendVarintField(b, 2, v)
+	case int64:
+		b = appendVarintField(b, 3, uint64(value))
+	case float64:
+		b = appendFixed64Field(b, 4, math.Float64bits(value))
+	}
+	return
+}
+
+// Encodes a KeyValue message.
+func encodeAttribute(attr attribute) (b []byte) {
+	b = appendStringField(b, 1, attr.Key)
+	return appendBytesField(b, 2, encodeValue(attr.Value))
+}
+
+// Encodes a LogRecord message.
+func encodeRecord(r *record) (b []byte) {
+	b = appendFixed64Field(b, 1, r.Time)
+	b = appendVarintField(b, 2, uint64(r.Severity))
+	b = appendStringField(b, 3, r.SeverityText)
+	b = appendBytesField(b, 5, encodeValue(r.Body))
+	for _, attr := range r.Attributes {
+		b = appendBytesField(b, 6, encodeAttribute(attr))
+	}
+	if r.HasSpan {
+		b = appendBytesField(b, 9, r.TraceID[:])
+		b = appendBytesField(b, 10, r.SpanID[:])
+	}
+	return appendFixed64Field(b, 11, r.ObservedTime)
+}
+
+// Encodes an ExportLogsServiceRequest message, containing a single ResourceLogs message.
+func encodeRequest(req *request) []byte {
+	var resource []byte
+	for _, attr := range req.Resource {
+		resource = appendBytesField(resource, 1, encodeAttribute(attr))
+	}
+	resourceLogs := appendBytesField(nil, 1, resource)
+	for _, scope := range req.Scopes {
+		scopeLogs := appendBytesField(nil, 1, appendStringField(nil, 1, scope.Name))
+		for _, r := range scope.Records {
+			scopeLogs = appendBytesField(scopeLogs, 2, encodeRecord(r))
+		}
+		resourceLogs = appendBytesField(resourceLogs, 2, scopeLogs)
+	}
+	return appendBytesField(nil, 1, resourceLogs)
+}
@@ -5,21 +5,21 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"strings"
 )
 
 // A WriterPlugin implements OutputPlugin by using a function to choose an io.Writer.
 type WriterPlugin func(options map[string]string) (writer io.Writer, err error)
 
-// Creates and returns a new StringOutputter. The format is taken from the "format" option, which must exist. The output
+// Creates and returns a new StringOutputter. The Formatter is created from the options by CreateFormatter. The output
 // StringWriter is obtained by calling the WriterPlugin (which is a function).
 func (chooser WriterPlugin) CreateOutputter(options map[string]string) (result Outputter, err error) {
 
 	// Setup formatter
-	format := options["format"]
-	if format == "" {
-		return nil, errors.New("console formatting string not specified")
+	formatter, err := CreateFormatter(options)
+	if err != nil {
+		return
 	}
-	formatter := NewBasicFormatter(format)
 
 	// Determine output stream to use
 	output, err := chooser(options)
@@ -33,6 +33,49 @@ func (chooser WriterPlugin) CreateOutputter(options map[string]string) (result O
 	}, nil
 }
 
+// A FormatterPlugin creates Formatters from simple key-value configuration variables.
+type FormatterPlugin func(options map[string]string) (Formatter, error)
+
+var formatterPlugins = make(map[string]FormatterPlugin)
+
+// Registers a formatter plugin by name. Output sections select a formatter with the "formatter" option.
+func RegisterFormatterPlugin(name string, plugin FormatterPlugin) {
+	lock.Lock()
+	defer lock.Unlock()
+	formatterPlugins[name] = plugin
+}
+
+// Creates a Formatter from an output section's options. The "formatter" option chooses the formatter plugin, and
+// defaults to "basic", which creates a BasicFormatter from the "format" option.
+func CreateFormatter(options map[string]string) (Formatter, error) {
+	name := options["formatter"]
+	if name == "" {
+		name = "basic"
+	}
+	lock.Lock()
+	plugin := formatterPlugins[name]
+	lock.Unlock()
+	if plugin == nil {
+		return nil, errors.New("unknown formatter: " + name)
+	}
+	return plugin(options)
+}
+
+var basicFormatterPlugin = FormatterPlugin(func(options map[string]string) (Formatter, error) {
+	format := options["format"]
+	if format == "" {
+		return nil, errors.New("formatting string not specified")
+	}
+	return NewBasicFormatter(format), nil
+})
+
+var jsonFormatterPlugin = FormatterPlugin(func(options map[string]string) (Formatter, error) {
+	return &JSONFormatter{TimeLayout: options["timeformat"]}, nil
+})
+
+// The prefix of the names of the files that the "console" plugin opens for file descriptor numbers.
+const consoleFilePrefix = "/dev/fd/"
+
 var consolePlugin = WriterPlugin(func(options map[string]string) (output io.Writer, err error) {
 	stream := options["stream"]
 	switch {
@@ -44,7 +87,7 @@ var consolePlugin = WriterPlugin(func(options map[string]string) (output io.Writ
 		err = errors.New("console stream not specified")
 	default:
 		if fd, err := strconv.Atoi(stream); err == nil {
-			output = os.NewFile(uintptr(fd), "logging_output")
+			output = os.NewFile(uintptr(fd), consoleFilePrefix+stream)
 		} else {
 			err = errors.New("invalid console stream: " + stream)
 		}
@@ -62,7 +105,84 @@ var filePlugin = WriterPlugin(func(options map[string]string) (output io.Writer,
 	return
 })
 
+// Splits a comma-separated list of section names and resolves each of them.
+func resolveList(list string, resolve func(name string) (Outputter, error)) (outputs []Outputter, err error) {
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var output Outputter
+		if output, err = resolve(name); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return
+}
+
+var teePlugin = CompositePluginFunc(func(options map[string]string, resolve func(string) (Outputter, error)) (Outputter, error) {
+	outputs, err := resolveList(options["outputs"], resolve)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) == 0 {
+		return nil, errors.New("tee outputs not specified")
+	}
+	return TeeOutputter(outputs), nil
+})
+
+var fallbackPlugin = CompositePluginFunc(func(options map[string]string, resolve func(string) (Outputter, error)) (result Outputter, err error) {
+	primaryName, secondaryName := options["primary"], options["secondary"]
+	if primaryName == "" {
+		return nil, errors.New("fallback primary output not specified")
+	}
+	if secondaryName == "" {
+		return nil, errors.New("fallback secondary output not specified")
+	}
+	var fallback FallbackOutputter
+	if fallback.Primary, err = resolve(primaryName); err != nil {
+		return
+	}
+	if fallback.Secondary, err = resolve(secondaryName); err != nil {
+		return
+	}
+	return fallback, nil
+})
+
+var roundRobinPlugin = CompositePluginFunc(func(options map[string]string, resolve func(string) (Outputter, error)) (Outputter, error) {
+	outputs, err := resolveList(options["outputs"], resolve)
+	if err != nil {
+		return nil, err
+	}
+	if len(outputs) == 0 {
+		return nil, errors.New("roundrobin outputs not specified")
+	}
+	return NewRoundRobinOutputter(outputs...), nil
+})
+
 func init() {
+	RegisterFormatterPlugin("basic", basicFormatterPlugin)
+	RegisterFormatterPlugin("json", jsonFormatterPlugin)
+	RegisterFormatterPlugin("logfmt", logfmtFormatterPlugin)
 	RegisterOutputPlugin("console", consolePlugin)
 	RegisterOutputPlugin("file", filePlugin)
+	RegisterOutputPlugin("http", httpPlugin)
+	RegisterCompositePlugin("tee", teePlugin)
+	RegisterCompositePlugin("fallback", fallbackPlugin)
+	RegisterCompositePlugin("roundrobin", roundRobinPlugin)
+	RegisterFormatterOptions("basic", "format")
+	RegisterFormatterOptions("json", "timeformat")
+	RegisterFormatterOptions("logfmt", "timeformat", "keys")
+	RegisterPluginOptions("console", "formatter", "stream")
+	RegisterPluginOptions("file", "formatter", "file")
+	RegisterPluginOptions("http", append([]string{"formatter", "timeformat", "url", "method", "body", "prefix", "content_type",
+		"headers", "bearer_token", "username", "password", "gzip", "timeout", "dead_letter"}, BatchPluginOptions...)...)
+	RegisterSecretOptions("http", "headers", "bearer_token", "username", "password")
+	RegisterPluginOptions("tee", "outputs")
+	RegisterPluginOptions("fallback", "primary", "secondary")
+	RegisterPluginOptions("roundrobin", "outputs")
+	RegisterReferenceOptions("tee", "outputs")
+	RegisterReferenceOptions("fallback", "primary", "secondary")
+	RegisterReferenceOptions("roundrobin", "outputs")
 }
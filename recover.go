@@ -0,0 +1,238 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// A PanicAction determines what happens after a recovered panic has been logged.
+type PanicAction int
+
+const (
+	PanicInherit PanicAction = iota // Use the action of the closest ancestor that has one, or PanicSwallow.
+	PanicSwallow                    // Stop the panic and continue normally.
+	PanicRepanic                    // Continue panicking with the original value.
+	PanicExit                       // Exit the program with a status of 1.
+)
+
+var panicActionStrings = map[PanicAction]string{
+	PanicSwallow: "swallow",
+	PanicRepanic: "repanic",
+	PanicExit:    "exit",
+}
+
+// Returns the name of the PanicAction, as accepted by ParsePanicAction.
+func (a PanicAction) String() string {
+	if s := panicActionStrings[a]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("PanicAction:%d", int(a))
+}
+
+// Parses the name of a PanicAction: "swallow", "repanic" or "exit". Names are not case sensitive.
+func ParsePanicAction(name string) (PanicAction, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for action, s := range panicActionStrings {
+		if s == name {
+			return action, nil
+		}
+	}
+	return PanicInherit, errors.New("unknown panic action: " + name)
+}
+
+var exit = os.Exit
+
+// Sets the level at which the Logger logs recovered panics. If Undefined, the level of the closest ancestor that has
+// one is used, and if there is none, panics are logged at Fatal.
+func (l *Logger) SetPanicLevel(level Level) {
+	l.hierarchy.lock.Lock()
+	defer l.hierarchy.lock.Unlock()
+	l.panicLevel = level
+}
+
+// Sets the action that the Logger takes after it has logged a recovered panic. If PanicInherit, the action of the
+// closest ancestor that has one is used, and if there is none, the panic is swallowed.
+func (l *Logger) SetOnPanic(action PanicAction) {
+	l.hierarchy.lock.Lock()
+	defer l.hierarchy.lock.Unlock()
+	l.onPanic = action
+}
+
+// Returns the level at which the Logger logs recovered panics, and the action it takes afterwards, as inherited from
+// its ancestors.
+func (l *Logger) panicSettings() (level Level, action PanicAction) {
+	l.hierarchy.lock.Lock()
+	defer l.hierarchy.lock.Unlock()
+	for logger := l; logger != nil && (level == Undefined || action == PanicInherit); logger = logger.parent {
+		if level == Undefined {
+			level = logger.panicLevel
+		}
+		if action == PanicInherit {
+			action = logger.onPanic
+		}
+	}
+	if level == Undefined {
+		level = Fatal
+	}
+	if action == PanicInherit {
+		action = PanicSwallow
+	}
+	return
+}
+
+// Recovers from a panic and logs it, along with the stack trace of the panicking goroutine. Recover must be called
+// directly by a deferred statement:
+//
+//	defer logger.Recover()
+//
+// The panic is logged at the Logger's panic level, and then the Logger's panic action determines what happens next (see
+// SetPanicLevel and SetOnPanic).
+func (l *Logger) Recover() {
+	if value := recover(); value != nil {
+		l.handlePanic(value, "", nil)
+	}
+}
+
+// Runs a function in a new goroutine, logging any panic with Logger.Recover.
+func Go(logger *Logger, fn func()) {
+	go func() {
+		defer logger.Recover()
+		fn()
+	}()
+}
+
+// Logs a recovered panic and then takes the Logger's panic action. The context is prepended to the panic value in the
+// message, and the fields are attached to it.
+func (l *Logger) handlePanic(value interface{}, context string, fields map[string]string) {
+	level, action := l.panicSettings()
+	msg := &Message{
+		Level:  level,
+		Msg:    fmt.Sprintf("%spanic: %v", context, value),
+		Time:   time.Now(),
+		Logger: l,
+		Stack:  panicStack(),
+		Fields: fields,
+	}
+	if err, ok := value.(error); ok {
+		msg.Errors = []error{err}
+	}
+	// The first frame of the stack trace is where the panic occurred
+	if lines := strings.SplitN(msg.Stack, "\n", 3); len(lines) >= 2 {
+		location := strings.TrimSpace(lines[1])
+		if i := strings.LastIndex(location, ":"); i >= 0 {
+			msg.File = location[:i]
+			fmt.Sscan(location[i+1:], &msg.Line)
+		}
+	}
+	if l.enabled(level) {
+		recordLogged(l, level)
+		l.doLog(msg)
+	}
+
+	// Buffered outputs are flushed first, since the program is likely to end
+	switch action {
+	case PanicRepanic:
+		l.hierarchy.flushBeforeExit()
+		panic(value)
+	case PanicExit:
+		l.hierarchy.flushBeforeExit()
+		exit(1)
+	}
+}
+
+// The longest time that a recovered panic waits for buffered outputs to be flushed before it continues to panic or
+// exits, so that an unreachable destination cannot keep the program from ending.
+var panicFlushTimeout = 5 * time.Second
+
+func (h *Hierarchy) flushBeforeExit() {
+	ctx, cancel := context.WithTimeout(context.Background(), panicFlushTimeout)
+	defer cancel()
+	h.FlushContext(ctx)
+}
+
+// Returns the stack trace of a panicking goroutine, starting at the function that panicked.
+func panicStack() string {
+	stack := captureStack(1)
+	lines := strings.Split(stack, "\n")
+	for i := 0; i+1 < len(lines); i += 2 {
+		if lines[i] == "runtime.gopanic" || lines[i] == "panic" {
+			return strings.Join(lines[i+2:], "\n")
+		}
+	}
+	return stack
+}
+
+// Records whether a response has been started, so that an error response is only sent if it has not.
+type recoverWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *recoverWriter) WriteHeader(code int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverWriter) Write(data []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Implements http.Flusher, if the wrapped ResponseWriter does.
+func (w *recoverWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.started = true
+		flusher.Flush()
+	}
+}
+
+// Implements http.Hijacker, if the wrapped ResponseWriter does.
+func (w *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	w.started = true
+	return hijacker.Hijack()
+}
+
+// Returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *recoverWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Returns an http.Handler that serves requests with the given handler, and logs any panic that occurs along with the
+// request's method, URL and remote address, which are also attached to the message as the "method", "url" and
+// "remote_addr" fields. If the panic is swallowed and no response has been started, an Internal Server Error response
+// is sent.
+func RecoverHandler(logger *Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+			fields := map[string]string{
+				"method":      r.Method,
+				"url":         r.URL.String(),
+				"remote_addr": r.RemoteAddr,
+			}
+			logger.handlePanic(value, fmt.Sprintf("%s %s from %s: ", r.Method, r.URL, r.RemoteAddr), fields)
+			if !rw.started {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		handler.ServeHTTP(rw, r)
+	})
+}
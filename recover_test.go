@@ -0,0 +1,209 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecover(t *testing.T) {
+	resetLoggers()
+	defer resetLoggers()
+	var logs msgSlice
+	logger := Get("recover")
+	logger.SetThreshold(Info)
+	logger.AddOutput(&logs)
+
+	func() {
+		defer logger.Recover()
+		panic("oh no")
+	}()
+
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(logs))
+	}
+	msg := logs[0]
+	if msg.Level != Fatal || msg.Msg != "panic: oh no" {
+		t.Errorf("unexpected message: %v %q", msg.Level, msg.Msg)
+	}
+	if !strings.HasPrefix(msg.Stack, "github.com/vaughan0/go-logging.TestRecover.func") {
+		t.Errorf("stack trace does not start at the panic: %s", msg.Stack)
+	}
+	if !strings.HasSuffix(msg.File, "recover_test.go") {
+		t.Errorf("unexpected file: %s", msg.File)
+	}
+}
+
+func TestRecoverHandler(t *testing.T) {
+	resetLoggers()
+	defer resetLoggers()
+	var logs msgSlice
+	logger := Get("recover")
+	logger.SetThreshold(Info)
+	logger.AddOutput(&logs)
+
+	handler := RecoverHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("bad request")
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/path", nil))
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", recorder.Code)
+	}
+	if len(logs) != 1 || !strings.HasPrefix(logs[0].Msg, "GET /path from ") {
+		t.Fatalf("unexpected messages: %v", logs)
+	}
+	if fields := logs[0].Fields; fields["method"] != "GET" || fields["url"] != "/path" || fields["remote_addr"] == "" {
+		t.Errorf("unexpected fields: %v", fields)
+	}
+
+	// A response that has been started is left alone
+	handler = RecoverHandler(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("after writing")
+	}))
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/path", nil))
+	if recorder.Code != http.StatusAccepted || recorder.Body.String() != "partial" {
+		t.Errorf("started response was changed: %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+// An output that buffers messages until it is flushed.
+type bufferedOutput struct {
+	buffered, flushed msgSlice
+}
+
+func (b *bufferedOutput) Output(msg *Message) {
+	b.buffered = append(b.buffered, msg)
+}
+
+func (b *bufferedOutput) Flush() error {
+	b.flushed = append(b.flushed, b.buffered...)
+	b.buffered = nil
+	return nil
+}
+
+func (b *bufferedOutput) CreateOutputter(options map[string]string) (Outputter, error) {
+	return b, nil
+}
+
+func TestPanicActions(t *testing.T) {
+	defer func(fn func(int)) { exit = fn }(exit)
+	var output bufferedOutput
+	h := NewHierarchy()
+	h.RegisterOutputPlugin("buffered", &output)
+	config := "[loggers]\nroot = INFO, buffered, onpanic=repanic\n[buffered]\ntype = buffered\n"
+	if err := h.SetupReader(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	logger := h.Get("panics")
+
+	func() {
+		defer func() {
+			if value := recover(); value != "again" {
+				t.Errorf("expected the panic to continue, got %v", value)
+			}
+		}()
+		defer logger.Recover()
+		panic("again")
+	}()
+	if len(output.flushed) != 1 || output.flushed[0].Msg != "panic: again" {
+		t.Errorf("panic was not logged and flushed before repanicking: %v", output.flushed)
+	}
+
+	logger.SetOnPanic(PanicExit)
+	logger.SetPanicLevel(Error)
+	status := -1
+	exit = func(code int) {
+		status = code
+		if len(output.flushed) != 2 {
+			t.Error("outputs were not flushed before exiting")
+		}
+	}
+	func() {
+		defer logger.Recover()
+		panic("exiting")
+	}()
+	if status != 1 {
+		t.Errorf("expected exit status 1, got %d", status)
+	}
+	if output.flushed[1].Level != Error {
+		t.Errorf("panic was logged at %s instead of the logger's panic level", output.flushed[1].Level)
+	}
+
+	// Other loggers still inherit the root logger's action
+	func() {
+		defer func() {
+			if value := recover(); value != "sibling" {
+				t.Errorf("expected the panic to continue, got %v", value)
+			}
+		}()
+		defer h.Get("other").Recover()
+		panic("sibling")
+	}()
+}
+
+// An output whose Flush method blocks until it is released.
+type blockedFlush chan struct{}
+
+func (b blockedFlush) Output(msg *Message) {}
+
+func (b blockedFlush) Flush() error {
+	<-b
+	return nil
+}
+
+func TestPanicFlushTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { panicFlushTimeout = timeout }(panicFlushTimeout)
+	panicFlushTimeout = 10 * time.Millisecond
+	defer func(fn func(int)) { exit = fn }(exit)
+	exited := false
+	exit = func(code int) { exited = true }
+
+	blocked := make(blockedFlush)
+	defer close(blocked)
+	h := NewHierarchy()
+	logger := h.Get("stuck")
+	logger.AddOutput(blocked)
+	logger.SetOnPanic(PanicExit)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer logger.Recover()
+		panic("stuck")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("exit waited for an output that does not finish flushing")
+	}
+	if !exited {
+		t.Error("program did not exit")
+	}
+}
+
+func TestGo(t *testing.T) {
+	h := NewHierarchy()
+	logged := make(chan *Message, 1)
+	logger := h.Get("goroutine")
+	logger.SetThreshold(Info)
+	logger.AddOutput(OutputterFunc(func(msg *Message) {
+		logged <- msg
+	}))
+	Go(logger, func() {
+		panic("in goroutine")
+	})
+	select {
+	case msg := <-logged:
+		if msg.Msg != "panic: in goroutine" || !strings.HasPrefix(msg.Stack, "github.com/vaughan0/go-logging.TestGo.func") {
+			t.Errorf("unexpected message: %q\n%s", msg.Msg, msg.Stack)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic in goroutine was not logged")
+	}
+}
@@ -0,0 +1,80 @@
+package logging
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// A loggerSelector applies logger settings to every logger whose name matches a pattern. In the "loggers" section, a
+// key that starts with "~" is a regular expression, which must match the whole logger name. Other keys that contain
+// any of the characters "*?[" are glob patterns, where each "*" matches a single part of a logger name (see
+// path.Match), so "worker.*.db" matches "worker.17.db" but not "worker.17.x.db".
+//
+// Settings for an exact logger name always take precedence over selectors. Otherwise, glob patterns take precedence
+// over regular expressions, and longer patterns over shorter ones, with ties broken alphabetically. A logger that is
+// matched by a selector does not inherit its ancestors' settings, but its descendants inherit from it as usual.
+type loggerSelector struct {
+	key    string
+	regexp *regexp.Regexp
+	config loggerConfig
+}
+
+// Reports whether a key in the "loggers" section is a selector rather than a logger name.
+func isSelector(key string) bool {
+	return strings.HasPrefix(key, "~") || strings.ContainsAny(key, "*?[")
+}
+
+func newLoggerSelector(key string, lc loggerConfig) (*loggerSelector, error) {
+	s := &loggerSelector{key: key, config: lc}
+	if strings.HasPrefix(key, "~") {
+		re, err := regexp.Compile(`^(?:` + key[1:] + `)$`)
+		if err != nil {
+			return nil, err
+		}
+		s.regexp = re
+	} else if _, err := path.Match(globPath(key), ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Converts a glob pattern or logger name to a path, so that path.Match treats the parts of the name as path elements.
+func globPath(name string) string {
+	return strings.Replace(name, ".", "/", -1)
+}
+
+func (s *loggerSelector) match(name string) bool {
+	if s.regexp != nil {
+		return s.regexp.MatchString(name)
+	}
+	matched, _ := path.Match(globPath(s.key), globPath(name))
+	return matched
+}
+
+// Sorts selectors by precedence, highest first.
+func sortSelectors(selectors []*loggerSelector) {
+	sort.Slice(selectors, func(i, j int) bool {
+		a, b := selectors[i], selectors[j]
+		if (a.regexp == nil) != (b.regexp == nil) {
+			return a.regexp == nil
+		}
+		if len(a.key) != len(b.key) {
+			return len(a.key) > len(b.key)
+		}
+		return a.key < b.key
+	})
+}
+
+// Applies the settings of the selector with the highest precedence that matches the logger, if there is one. The
+// hierarchy's selectors and outputters must not be modified while this is running.
+func (h *Hierarchy) selectLogger(logger *Logger) {
+	for _, s := range h.selectors {
+		if s.match(logger.Name) {
+			logger.selector = s.key
+			applyLoggerConfig(logger, s.config, h.outputters)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoggerSelectors(t *testing.T) {
+	var msgs msgSlice
+	h := NewHierarchy()
+	h.RegisterOutputPlugin("mock", &msgs)
+	existing := h.Get("worker.17.db")
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, mock
+  worker = ERROR
+  worker.*.db = DEBUG
+  worker.*.* = WARN
+  worker.3.db = NOTICE
+  ~^http\..*handler$ = WARN
+
+  [mock]
+  type = mock
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		threshold Level
+	}{
+		{"worker.17.db", Debug},      // Existing logger, longer glob wins
+		{"worker.18.db", Debug},      // Created after setup
+		{"worker.18.db.conn", Debug}, // Inherited from a selected ancestor
+		{"worker.18.cache", Warn},    // Shorter glob
+		{"worker.18", Error},         // Not matched, inherited from an ancestor
+		{"worker.3.db", Notice},      // Exact names take precedence
+		{"http.api.handler", Warn},   // Regular expression
+		{"http.api.handler.x", Warn}, // Inherited from a selected ancestor
+		{"http.api.handlers", Info},  // Regular expressions match the whole name
+		{"other.http.handler", Info}, // Regular expressions match the whole name
+	}
+	for _, test := range tests {
+		if level := h.Get(test.name).EffectiveThreshold(); level != test.threshold {
+			t.Errorf("%s: threshold is %v, expected %v", test.name, level, test.threshold)
+		}
+	}
+	if existing != h.Get("worker.17.db") {
+		t.Error("logger was replaced")
+	}
+
+	dump := new(strings.Builder)
+	if err := h.DumpConfig(dump); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dump.String(), "worker.*.db = DEBUG\n") || strings.Contains(dump.String(), "worker.17.db") {
+		t.Errorf("unexpected dump:\n%s", dump)
+	}
+
+	// A new configuration removes the selectors from existing loggers
+	if err := h.SetupReader(strings.NewReader("[loggers]\nroot = INFO, mock\n[mock]\ntype = mock\n")); err != nil {
+		t.Fatal(err)
+	}
+	if level := h.Get("worker.17.db").EffectiveThreshold(); level != Info {
+		t.Errorf("selector was not removed: %v", level)
+	}
+
+	err = h.SetupReader(strings.NewReader("[loggers]\n~a( = INFO, mock\n[mock]\ntype = mock\n"))
+	if err == nil || !strings.Contains(err.Error(), "invalid logger selector") {
+		t.Errorf("unexpected error for invalid selector: %v", err)
+	}
+}
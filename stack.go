@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+)
+
+// Reports whether a stack trace should be captured for a message with the given level, and whether it is only needed by
+// outputs with a stack trace level of their own.
+func (l *Logger) captureStack(level Level) (capture, forOutputs bool) {
+	if stackTrace := l.effectiveStackTrace(); stackTrace != Undefined && level >= stackTrace {
+		return true, false
+	}
+	threshold := Level(atomic.LoadInt64(&l.hierarchy.outputStackTrace))
+	if threshold != Undefined && level >= threshold {
+		return true, true
+	}
+	return false, false
+}
+
+// A StackWanter is an Outputter that can report whether it needs the stack traces that are only captured for outputs
+// with a stack trace level of their own (see StackOutputter). Such stack traces are removed before a message is sent
+// to an output that does not need them. Outputs that wrap other outputs implement StackWanter to report whether any of
+// them needs stack traces, and use StackFor to remove them for the others.
+type StackWanter interface {
+	Outputter
+	WantsStack() bool
+}
+
+// Reports whether an output needs the stack traces that are only captured for outputs with a stack trace level of their
+// own.
+func wantsStack(o Outputter) bool {
+	wanter, ok := o.(StackWanter)
+	return ok && wanter.WantsStack()
+}
+
+// Returns the message that should be sent to an output, which is a copy without its stack trace if the stack trace was
+// only captured for outputs with a stack trace level of their own, and the output does not need it.
+func StackFor(o Outputter, msg *Message) *Message {
+	if msg.outputStack && !wantsStack(o) {
+		return withoutStack(msg)
+	}
+	return msg
+}
+
+// Returns a copy of a message without its stack trace.
+func withoutStack(msg *Message) *Message {
+	stripped := *msg
+	stripped.Stack = ""
+	stripped.outputStack = false
+	return &stripped
+}
+
+// Formats the stack of the calling goroutine, skipping the given number of frames.
+func captureStack(skip int) string {
+	pcs := make([]uintptr, 64)
+	for {
+		n := runtime.Callers(skip+1, pcs)
+		if n < len(pcs) {
+			pcs = pcs[:n]
+			break
+		}
+		pcs = make([]uintptr, len(pcs)*2)
+	}
+	var result bytes.Buffer
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(&result, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+		if !more {
+			break
+		}
+	}
+	return result.String()
+}
+
+// StackOutputter wraps an Outputter and removes stack traces from messages that do not meet a certain level.
+type StackOutputter struct {
+	StackTrace Level
+	Outputter  Outputter
+}
+
+// Implements Outputter.
+func (s StackOutputter) Output(msg *Message) {
+	s.OutputErr(msg)
+}
+
+// Implements StackWanter.
+func (s StackOutputter) WantsStack() bool {
+	return true
+}
+
+// Implements ErrorOutputter.
+func (s StackOutputter) OutputErr(msg *Message) error {
+	if msg.Stack != "" && msg.Level < s.StackTrace {
+		msg = withoutStack(msg)
+	}
+	return OutputErr(s.Outputter, msg)
+}
+
+// ErrorInfo describes a single error in an error chain.
+type ErrorInfo struct {
+	// The Go type of the error.
+	Type string `json:"type"`
+	// The result of the error's Error method.
+	Msg string `json:"msg"`
+	// The stack trace embedded in the error, if it has a StackTrace method (as errors from github.com/pkg/errors do).
+	Stack string `json:"stack,omitempty"`
+}
+
+// Returns information about an error and every error it wraps, as returned by errors.Unwrap. Errors that wrap several
+// errors with an Unwrap() []error method, such as those returned by errors.Join, are followed by each of the wrapped
+// errors and the errors they wrap in turn.
+func ErrorChain(err error) (chain []ErrorInfo) {
+	for err != nil {
+		chain = append(chain, ErrorInfo{
+			Type:  fmt.Sprintf("%T", err),
+			Msg:   err.Error(),
+			Stack: errorStack(err),
+		})
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, wrapped := range joined.Unwrap() {
+				chain = append(chain, ErrorChain(wrapped)...)
+			}
+			return
+		}
+		err = errors.Unwrap(err)
+	}
+	return
+}
+
+// Returns the stack trace embedded in an error, by calling its StackTrace method if it has one.
+func errorStack(err error) string {
+	method := reflect.ValueOf(err).MethodByName("StackTrace")
+	if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+		return ""
+	}
+	return fmt.Sprintf("%+v", method.Call(nil)[0].Interface())
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStackTrace(t *testing.T) {
+	resetLoggers()
+	defer resetLoggers()
+	var logs msgSlice
+	logger := Get("stack")
+	logger.SetThreshold(Info)
+	logger.StackTrace = Error
+	logger.AddOutput(&logs)
+
+	cause := errors.New("connection refused")
+	logger.Info("no stack")
+	logger.Error("request failed: ", fmt.Errorf("fetching: %w", cause))
+
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(logs))
+	}
+	if logs[0].Stack != "" {
+		t.Errorf("unexpected stack trace for INFO message: %s", logs[0].Stack)
+	}
+	if !strings.HasPrefix(logs[1].Stack, "github.com/vaughan0/go-logging.TestStackTrace\n") {
+		t.Errorf("stack trace does not start at the logging statement: %s", logs[1].Stack)
+	}
+
+	var decoded struct {
+		Stack  string
+		Errors [][]ErrorInfo
+	}
+	if err := json.Unmarshal([]byte((&JSONFormatter{}).Format(logs[1])), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Stack != logs[1].Stack {
+		t.Errorf("stack field does not match: %s", decoded.Stack)
+	}
+	if len(decoded.Errors) != 1 || len(decoded.Errors[0]) != 2 || decoded.Errors[0][1].Msg != "connection refused" {
+		t.Errorf("unexpected error chain: %v", decoded.Errors)
+	}
+}
+
+func TestOutputStackTrace(t *testing.T) {
+	h := NewHierarchy()
+	var a, b, c, d, e msgSlice
+	h.RegisterOutputPlugin("a", &a)
+	h.RegisterOutputPlugin("b", &b)
+	h.RegisterOutputPlugin("c", &c)
+	h.RegisterOutputPlugin("d", &d)
+	h.RegisterOutputPlugin("e", &e)
+	err := h.SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, a, b, both, mixed
+
+  [a]
+  type = a
+  stacktrace = ERROR
+
+  [b]
+  type = b
+
+  [c]
+  type = c
+
+  [both]
+  type = tee
+  outputs = c
+
+  [d]
+  type = d
+  stacktrace = ERROR
+
+  [e]
+  type = e
+
+  [mixed]
+  type = tee
+  outputs = d, e
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Get("x").Error("failed")
+	h.Get("x").Warn("warning")
+
+	if len(a) != 2 || a[0].Stack == "" || a[1].Stack != "" {
+		t.Errorf("output with a stacktrace option did not receive the expected stack traces")
+	}
+	if len(b) != 2 || b[0].Stack != "" || len(c) != 2 || c[0].Stack != "" {
+		t.Errorf("outputs without a stacktrace option received a stack trace")
+	}
+	if len(d) != 2 || d[0].Stack == "" || len(e) != 2 || e[0].Stack != "" {
+		t.Errorf("stack trace was not limited to the member of a composite output that requested it")
+	}
+
+	// A stack trace requested by the logger itself is sent to every output
+	h.Get("x").StackTrace = Error
+	h.Get("x").Error("failed again")
+	if b[2].Stack == "" || c[2].Stack == "" {
+		t.Errorf("logger stack trace was not sent to every output")
+	}
+}
+
+func TestErrorChainJoined(t *testing.T) {
+	first := errors.New("first")
+	second := fmt.Errorf("second: %w", errors.New("cause"))
+	chain := ErrorChain(fmt.Errorf("failed: %w", errors.Join(first, second)))
+
+	var msgs []string
+	for _, info := range chain {
+		msgs = append(msgs, info.Msg)
+	}
+	expected := []string{"failed: first\nsecond: cause", "first\nsecond: cause", "first", "second: cause", "cause"}
+	if strings.Join(msgs, "|") != strings.Join(expected, "|") {
+		t.Errorf("unexpected error chain: %q", msgs)
+	}
+}
@@ -15,6 +15,12 @@ type StringWriter interface {
 	Write(str string)
 }
 
+// An ErrorStringWriter is a StringWriter that can report whether a string was successfully written.
+type ErrorStringWriter interface {
+	StringWriter
+	WriteErr(str string) error
+}
+
 // StringOutputter implements Outputter by combining a Formatter and a StringWriter.
 type StringOutputter struct {
 	Formatter Formatter
@@ -26,6 +32,16 @@ func (s StringOutputter) Output(msg *Message) {
 	s.Writer.Write(s.Formatter.Format(msg))
 }
 
+// Implements ErrorOutputter. Errors are only reported if the StringWriter is an ErrorStringWriter.
+func (s StringOutputter) OutputErr(msg *Message) error {
+	str := s.Formatter.Format(msg)
+	if writer, ok := s.Writer.(ErrorStringWriter); ok {
+		return writer.WriteErr(str)
+	}
+	s.Writer.Write(str)
+	return nil
+}
+
 // IOWriter implements StringWriter by writing lines to an io.Writer.
 type IOWriter struct {
 	Writer io.Writer
@@ -33,10 +49,18 @@ type IOWriter struct {
 
 // Implements StringWriter.
 func (w IOWriter) Write(str string) {
-	io.WriteString(w.Writer, str+"\n")
+	w.WriteErr(str)
+}
+
+// Implements ErrorStringWriter.
+func (w IOWriter) WriteErr(str string) (err error) {
+	if _, err = io.WriteString(w.Writer, str+"\n"); err != nil {
+		return
+	}
 	if bufout, ok := w.Writer.(*bufio.Writer); ok {
-		bufout.Flush()
+		err = bufout.Flush()
 	}
+	return
 }
 
 // ThresholdOutputter wraps an Outputter and only forwards messages that meet a certain threshold level.
@@ -46,10 +70,20 @@ type ThresholdOutputter struct {
 }
 
 func (t ThresholdOutputter) Output(msg *Message) {
+	t.OutputErr(msg)
+}
+
+// Implements StackWanter.
+func (t ThresholdOutputter) WantsStack() bool {
+	return wantsStack(t.Outputter)
+}
+
+// Implements ErrorOutputter.
+func (t ThresholdOutputter) OutputErr(msg *Message) error {
 	if t.Threshold > msg.Level {
-		return
+		return nil
 	}
-	t.Outputter.Output(msg)
+	return OutputErr(t.Outputter, msg)
 }
 
 // BasicFormatter uses simple string templates to format messages.
@@ -58,6 +92,7 @@ type BasicFormatter struct {
 	// contains the keys "date" (just the date), "time" (just the time), and "datetime" (date and time).
 	DateVars map[string]string
 	template []templatePart
+	source   string
 }
 
 var templateRegex = regexp.MustCompile(`^(\$[a-zA-Z]+|\$\$|[^\$]+)`)
@@ -70,6 +105,8 @@ var templateRegex = regexp.MustCompile(`^(\$[a-zA-Z]+|\$\$|[^\$]+)`)
 //		file      The name of the file where the logging statement originated.
 //		line      The line number where the logging statement originated.
 //		logger    The name of the logger which was used to log the message.
+//		stack     The stack trace captured for the message, if any (see Logger.StackTrace).
+//		traced    "traced" if the message was logged with tracing enabled (see WithTracing), otherwise empty.
 // Variables from DateVars ($date, $time and $datetime, by default) are also included.
 //
 // For example: If the template is "[$level] $time - $msg\n", then the call logger.Warn("oh no!") could produce
@@ -94,6 +131,7 @@ func NewBasicFormatter(template string) *BasicFormatter {
 	}
 	return &BasicFormatter{
 		template: parts,
+		source:   template,
 		DateVars: map[string]string{
 			"date":     "02/01/2006",
 			"time":     "15:04:05",
@@ -123,6 +161,11 @@ func (b *BasicFormatter) getVars(msg *Message) map[string]string {
 		"file":   path.Base(msg.File),
 		"line":   strconv.Itoa(msg.Line),
 		"logger": msg.Logger.Name,
+		"stack":  msg.Stack,
+		"traced": "",
+	}
+	if msg.Traced {
+		vars["traced"] = "traced"
 	}
 	for key, layout := range b.DateVars {
 		vars[key] = msg.Time.Format(layout)
@@ -0,0 +1,195 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StructuredConfig implements Config for nested configuration formats, such as JSON, YAML and TOML. It is created from
+// a decoded document by NewStructuredConfig.
+//
+// The document has the same layout as an INI file: the "loggers" key holds logger settings, and every other top-level
+// key defines an output. Logger settings may be strings in the INI format, lists of the same parts, or objects with
+// "level", "outputs", "nopropagate", "stacktrace", "paniclevel" and "onpanic" keys. Wherever an output name is expected
+// (in a logger's outputs or in an option of a composite output), an object can be used instead to define an output
+// inline.
+type StructuredConfig struct {
+	Loggers map[string]string
+	Outputs []PluginConfig
+}
+
+// Implements Config.
+func (s *StructuredConfig) LoggerSettings() map[string]string {
+	return s.Loggers
+}
+
+// Implements Config.
+func (s *StructuredConfig) Plugins() []PluginConfig {
+	return s.Outputs
+}
+
+// Creates a StructuredConfig from a decoded document.
+func NewStructuredConfig(document map[string]interface{}) (*StructuredConfig, error) {
+	s := &StructuredConfig{Loggers: make(map[string]string)}
+
+	for _, name := range sortedKeys(document) {
+		value := document[name]
+		if name == "loggers" {
+			loggers, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, errors.New("loggers must be an object")
+			}
+			for _, logger := range sortedKeys(loggers) {
+				setting, err := s.loggerSetting("loggers."+logger, loggers[logger])
+				if err != nil {
+					return nil, err
+				}
+				s.Loggers[logger] = setting
+			}
+			continue
+		}
+		options, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("output must be an object: " + name)
+		}
+		if err := s.addOutput(name, options); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Converts a logger's settings to the INI format.
+func (s *StructuredConfig) loggerSetting(path string, value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case []interface{}:
+		return s.joinList(path, value)
+	case map[string]interface{}:
+		level, ok := value["level"].(string)
+		if !ok {
+			return "", errors.New("logger level not specified: " + path)
+		}
+		parts := []string{level}
+		if outputs, ok := value["outputs"]; ok {
+			list, err := s.optionValue(path+".outputs", outputs)
+			if err != nil {
+				return "", err
+			}
+			if list != "" {
+				parts = append(parts, list)
+			}
+		}
+		if nopropagate, _ := value["nopropagate"].(bool); nopropagate {
+			parts = append(parts, "nopropagate")
+		}
+		for _, key := range []string{"stacktrace", "paniclevel", "onpanic"} {
+			if option, ok := value[key].(string); ok {
+				parts = append(parts, key+"="+option)
+			}
+		}
+		return strings.Join(parts, ", "), nil
+	}
+	return "", errors.New("invalid logger settings: " + path)
+}
+
+// Adds an output section, along with any outputs that are defined inline in its options.
+func (s *StructuredConfig) addOutput(name string, options map[string]interface{}) error {
+	converted := make(map[string]string)
+	for _, key := range sortedKeys(options) {
+		value, err := s.optionValue(name+"."+key, options[key])
+		if err != nil {
+			return err
+		}
+		converted[key] = value
+	}
+	s.Outputs = append(s.Outputs, PluginConfig{
+		Name:    name,
+		Options: converted,
+	})
+	return nil
+}
+
+// Converts an option value to a string. Lists are joined with commas, and objects are added as inline outputs, which
+// are referred to by their path.
+func (s *StructuredConfig) optionValue(path string, value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case bool, int, int64, uint64:
+		return fmt.Sprint(value), nil
+	case float64:
+		// Large numbers are written in full rather than with an exponent, which option parsers do not accept
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case json.Number:
+		return value.String(), nil
+	case []interface{}:
+		return s.joinList(path, value)
+	case []map[string]interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = item
+		}
+		return s.joinList(path, list)
+	case map[string]interface{}:
+		return path, s.addOutput(path, value)
+	}
+	return "", fmt.Errorf("invalid value for %s: %v", path, value)
+}
+
+func (s *StructuredConfig) joinList(path string, list []interface{}) (string, error) {
+	parts := make([]string, len(list))
+	for i, item := range list {
+		part, err := s.optionValue(path+"."+strconv.Itoa(i), item)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return strings.Join(parts, ", "), nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Loads a StructuredConfig from an io.Reader, which should return a JSON object.
+func LoadJSONConfig(input io.Reader) (*StructuredConfig, error) {
+	var document map[string]interface{}
+	decoder := json.NewDecoder(input)
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return NewStructuredConfig(document)
+}
+
+// A ConfigLoader reads a Config from an io.Reader.
+type ConfigLoader func(input io.Reader) (Config, error)
+
+var configFormats = make(map[string]ConfigLoader)
+
+// Registers a ConfigLoader for files with the given extension (including the leading dot), which will be used by
+// SetupFile.
+func RegisterConfigFormat(extension string, loader ConfigLoader) {
+	lock.Lock()
+	defer lock.Unlock()
+	configFormats[strings.ToLower(extension)] = loader
+}
+
+func init() {
+	RegisterConfigFormat(".json", func(input io.Reader) (Config, error) {
+		return LoadJSONConfig(input)
+	})
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONConfig(t *testing.T) {
+	config, err := LoadJSONConfig(strings.NewReader(`{
+		"loggers": {
+			"root": "INFO, console",
+			"a.b": {
+				"level": "DEBUG",
+				"outputs": ["console", {"type": "tee", "outputs": ["console", "file"]}],
+				"nopropagate": true
+			}
+		},
+		"console": {"type": "console", "stream": "stderr", "format": "$msg"},
+		"file": {"type": "file", "file": "/dev/null", "format": "$msg", "threshold": "WARN"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectLoggers := map[string]string{
+		"root": "INFO, console",
+		"a.b":  "DEBUG, console, loggers.a.b.outputs.1, nopropagate",
+	}
+	if !reflect.DeepEqual(config.LoggerSettings(), expectLoggers) {
+		t.Errorf("unexpected logger settings: %v", config.LoggerSettings())
+	}
+	expectOutputs := []PluginConfig{
+		{"console", map[string]string{"type": "console", "stream": "stderr", "format": "$msg"}},
+		{"file", map[string]string{"type": "file", "file": "/dev/null", "format": "$msg", "threshold": "WARN"}},
+		{"loggers.a.b.outputs.1", map[string]string{"type": "tee", "outputs": "console, file"}},
+	}
+	if !reflect.DeepEqual(config.Plugins(), expectOutputs) {
+		t.Errorf("unexpected outputs: %v", config.Plugins())
+	}
+	if err := SetupConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	resetLoggers()
+}
+
+func TestJSONConfigNumbers(t *testing.T) {
+	config, err := LoadJSONConfig(strings.NewReader(`{
+		"loggers": {"root": "INFO, out"},
+		"out": {"type": "http", "queue_size": 1000000, "max_retries": 12345678901, "ratio": 0.25}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := config.Plugins()[0].Options
+	if options["queue_size"] != "1000000" || options["max_retries"] != "12345678901" || options["ratio"] != "0.25" {
+		t.Errorf("numbers were not converted exactly: %v", options)
+	}
+
+	// Documents decoded by other formats may contain large floating point numbers
+	structured, err := NewStructuredConfig(map[string]interface{}{
+		"out": map[string]interface{}{"type": "http", "queue_size": float64(1000000)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := structured.Plugins()[0].Options["queue_size"]; size != "1000000" {
+		t.Errorf("expected queue_size 1000000, got %s", size)
+	}
+}
@@ -33,22 +33,27 @@ func NewSyslog(format logging.Formatter, tag string) (*SyslogOutputter, error) {
 
 // Implements Outputter.
 func (s SyslogOutputter) Output(msg *logging.Message) {
+	s.OutputErr(msg)
+}
+
+// Implements logging.ErrorOutputter.
+func (s SyslogOutputter) OutputErr(msg *logging.Message) error {
 	str := s.Formatter.Format(msg)
 	switch msg.Level {
 	case logging.Fatal:
-		s.Writer.Crit(str)
+		return s.Writer.Crit(str)
 	case logging.Error:
-		s.Writer.Err(str)
+		return s.Writer.Err(str)
 	case logging.Warn:
-		s.Writer.Warning(str)
+		return s.Writer.Warning(str)
 	case logging.Notice:
-		s.Writer.Notice(str)
+		return s.Writer.Notice(str)
 	case logging.Info:
-		s.Writer.Info(str)
+		return s.Writer.Info(str)
 	case logging.Debug, logging.Trace:
-		s.Writer.Debug(str)
+		return s.Writer.Debug(str)
 	default:
-		s.Writer.Notice(str)
+		return s.Writer.Notice(str)
 	}
 }
 
@@ -78,9 +83,9 @@ var facilityMap = map[string]syslog.Priority{
 var syslogPlugin = logging.OutputPluginFunc(func(options map[string]string) (result logging.Outputter, err error) {
 
 	// Setup formatter
-	format := options["format"]
-	if format == "" {
-		return nil, errors.New("syslog formatting string not specified")
+	formatter, err := logging.CreateFormatter(options)
+	if err != nil {
+		return
 	}
 
 	tag := options["tag"]
@@ -95,9 +100,10 @@ var syslogPlugin = logging.OutputPluginFunc(func(options map[string]string) (res
 		}
 	}
 
-	return NewSyslogFacility(logging.NewBasicFormatter(format), tag, facility)
+	return NewSyslogFacility(formatter, tag, facility)
 })
 
 func init() {
 	logging.RegisterOutputPlugin("syslog", syslogPlugin)
+	logging.RegisterPluginOptions("syslog", "formatter", "tag", "facility")
 }
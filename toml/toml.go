@@ -0,0 +1,24 @@
+// Package toml adds support for TOML configuration files to go-logging. Importing it registers the ".toml" extension
+// with logging.SetupFile.
+package toml
+
+import (
+	"github.com/BurntSushi/toml"
+	"github.com/vaughan0/go-logging"
+	"io"
+)
+
+// Loads a logging.StructuredConfig from an io.Reader, which should return a TOML document.
+func LoadTOMLConfig(input io.Reader) (*logging.StructuredConfig, error) {
+	var document map[string]interface{}
+	if _, err := toml.NewDecoder(input).Decode(&document); err != nil {
+		return nil, err
+	}
+	return logging.NewStructuredConfig(document)
+}
+
+func init() {
+	logging.RegisterConfigFormat(".toml", func(input io.Reader) (logging.Config, error) {
+		return LoadTOMLConfig(input)
+	})
+}
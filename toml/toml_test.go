@@ -0,0 +1,89 @@
+package toml
+
+import (
+	"github.com/vaughan0/go-logging"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Checks that a TOML document produces the same configuration as a JSON document.
+func expectSameConfig(t *testing.T, document, jsonDocument string) {
+	t.Helper()
+	config, err := LoadTOMLConfig(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := logging.LoadJSONConfig(strings.NewReader(jsonDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.LoggerSettings(), expect.LoggerSettings()) {
+		t.Errorf("unexpected logger settings: %v", config.LoggerSettings())
+	}
+	if !reflect.DeepEqual(config.Plugins(), expect.Plugins()) {
+		t.Errorf("unexpected outputs: %v", config.Plugins())
+	}
+}
+
+func TestLoadTOMLConfig(t *testing.T) {
+	// The TOML version of the document in the logging package's TestJSONConfig
+	expectSameConfig(t, `
+[loggers]
+root = "INFO, console"
+
+[loggers."a.b"]
+level = "DEBUG"
+outputs = ["console", {type = "tee", outputs = ["console", "file"]}]
+nopropagate = true
+
+[console]
+type = "console"
+stream = "stderr"
+format = "$msg"
+
+[file]
+type = "file"
+file = "/dev/null"
+format = "$msg"
+threshold = "WARN"
+`, `{
+	"loggers": {
+		"root": "INFO, console",
+		"a.b": {
+			"level": "DEBUG",
+			"outputs": ["console", {"type": "tee", "outputs": ["console", "file"]}],
+			"nopropagate": true
+		}
+	},
+	"console": {"type": "console", "stream": "stderr", "format": "$msg"},
+	"file": {"type": "file", "file": "/dev/null", "format": "$msg", "threshold": "WARN"}
+}`)
+}
+
+func TestArraysOfTables(t *testing.T) {
+	// Arrays of tables are decoded as []map[string]interface{}, rather than []interface{}
+	expectSameConfig(t, `
+[loggers.app]
+level = "INFO"
+
+[[loggers.app.outputs]]
+type = "console"
+format = "$msg"
+
+[[loggers.app.outputs]]
+type = "file"
+file = "/dev/null"
+
+[tee]
+type = "tee"
+
+[[tee.outputs]]
+type = "console"
+`, `{
+	"loggers": {
+		"app": {"level": "INFO", "outputs": [{"type": "console", "format": "$msg"}, {"type": "file", "file": "/dev/null"}]}
+	},
+	"tee": {"type": "tee", "outputs": [{"type": "console"}]}
+}`)
+}
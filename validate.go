@@ -0,0 +1,342 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A ConfigProblem describes a problem found in a configuration, and where it was found.
+type ConfigProblem struct {
+	// The section containing the problem. Logger settings are in the "loggers" section.
+	Section string
+	// The key containing the problem, or "" if the problem is with the whole section.
+	Key string
+	// The underlying error.
+	Err error
+	// Warnings describe suspicious configurations that can still be applied, such as unused outputs.
+	Warning bool
+}
+
+func (p ConfigProblem) Error() string {
+	location := "[" + p.Section + "]"
+	if p.Key != "" {
+		location += " " + p.Key
+	}
+	if p.Warning {
+		location += " (warning)"
+	}
+	return location + ": " + p.Err.Error()
+}
+
+func (p ConfigProblem) Unwrap() error {
+	return p.Err
+}
+
+// ConfigErrors is a list of problems found in a configuration.
+type ConfigErrors []ConfigProblem
+
+func (c ConfigErrors) Error() string {
+	msgs := make([]string, len(c))
+	for i, problem := range c {
+		msgs[i] = problem.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Allows errors.Is and errors.As to match the errors of individual problems.
+func (c ConfigErrors) Unwrap() []error {
+	errs := make([]error, len(c))
+	for i, problem := range c {
+		errs[i] = problem
+	}
+	return errs
+}
+
+// Returns only the problems that are not warnings.
+func (c ConfigErrors) Errors() (errs ConfigErrors) {
+	for _, problem := range c {
+		if !problem.Warning {
+			errs = append(errs, problem)
+		}
+	}
+	return
+}
+
+var formatterOptions = make(map[string][]string)
+
+// The options that are accepted by every output section.
+var standardOptions = []string{"type", "threshold", "stacktrace"}
+
+// Declares the options accepted by a plugin, in addition to the standard "type", "threshold" and "stacktrace" options.
+// If the options include "formatter", then the options of the chosen formatter are accepted too. ValidateConfig reports
+// unknown options in the sections of plugins that have declared their options.
+func (h *Hierarchy) RegisterPluginOptions(name string, options ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pluginOptions[name] = options
+}
+
+// Declares the options accepted by a plugin of the Default hierarchy (see Hierarchy.RegisterPluginOptions).
+func RegisterPluginOptions(name string, options ...string) {
+	Default.RegisterPluginOptions(name, options...)
+}
+
+// Declares the options of a composite plugin that refer to other sections, each of which holds a comma-separated list of
+// section names. ValidateConfig checks these references without creating any outputters, so composite plugins are never
+// called during validation; the references of composite plugins that have not declared them are only checked when a
+// configuration is applied.
+func (h *Hierarchy) RegisterReferenceOptions(name string, options ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.referenceOptions[name] = options
+}
+
+// Declares the reference options of a composite plugin of the Default hierarchy (see
+// Hierarchy.RegisterReferenceOptions).
+func RegisterReferenceOptions(name string, options ...string) {
+	Default.RegisterReferenceOptions(name, options...)
+}
+
+// Declares the options accepted by a formatter plugin.
+func RegisterFormatterOptions(name string, options ...string) {
+	lock.Lock()
+	defer lock.Unlock()
+	formatterOptions[name] = options
+}
+
+// Checks a configuration without applying it, and returns every problem that was found as ConfigErrors. Output
+// sections are not created, but composite sections are checked for references to unknown or cyclic sections. Nil is
+// returned if no problems were found, including warnings.
+func (h *Hierarchy) ValidateConfig(config Config) error {
+	return h.validateConfig(config, nil)
+}
+
+// Like ValidateConfig, but the configuration may also refer to predefined outputters, which are not checked.
+func (h *Hierarchy) validateConfig(config Config, predefined map[string]Outputter) error {
+	v := validator{
+		hierarchy:  h,
+		sections:   make(map[string]map[string]string),
+		predefined: predefined,
+		references: make(map[string][]string),
+		used:       make(map[string]bool),
+	}
+	plugins := config.Plugins()
+	for _, pluginCfg := range plugins {
+		v.sections[pluginCfg.Name] = pluginCfg.Options
+	}
+	for _, pluginCfg := range plugins {
+		v.checkOutput(pluginCfg.Name, pluginCfg.Options)
+	}
+	loggers := config.LoggerSettings()
+	for _, name := range sortedStrings(loggers) {
+		v.checkLogger(name, loggers[name])
+	}
+	v.checkCycles()
+	for _, pluginCfg := range plugins {
+		if !v.used[pluginCfg.Name] {
+			v.warn(pluginCfg.Name, "", errors.New("output is not used"))
+		}
+	}
+
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return v.problems
+}
+
+// Checks a configuration against the plugins of the Default hierarchy (see Hierarchy.ValidateConfig).
+func ValidateConfig(config Config) error {
+	return Default.ValidateConfig(config)
+}
+
+// Loads a configuration file in the same way as SetupFile, and checks it with ValidateConfig.
+func (h *Hierarchy) ValidateFile(filename string) error {
+	config, err := loadFiles(filename)
+	if err != nil {
+		return err
+	}
+	return h.ValidateConfig(config)
+}
+
+// Checks a configuration file against the plugins of the Default hierarchy (see Hierarchy.ValidateFile).
+func ValidateFile(filename string) error {
+	return Default.ValidateFile(filename)
+}
+
+type validator struct {
+	hierarchy  *Hierarchy
+	sections   map[string]map[string]string
+	predefined map[string]Outputter
+	references map[string][]string
+	used       map[string]bool
+	problems   ConfigErrors
+}
+
+func (v *validator) problem(section, key string, err error) {
+	v.problems = append(v.problems, ConfigProblem{Section: section, Key: key, Err: err})
+}
+
+func (v *validator) warn(section, key string, err error) {
+	v.problems = append(v.problems, ConfigProblem{Section: section, Key: key, Err: err, Warning: true})
+}
+
+func (v *validator) checkLevel(section, key, value string) {
+	if _, ok := reverseLevelStrings[strings.ToUpper(strings.TrimSpace(value))]; !ok {
+		v.problem(section, key, errors.New("unknown logging level: "+value))
+	}
+}
+
+func (v *validator) checkLogger(name, settings string) {
+	if isSelector(name) {
+		if _, err := newLoggerSelector(name, loggerConfig{}); err != nil {
+			v.problem("loggers", name, fmt.Errorf("invalid logger selector: %v", err))
+		}
+	}
+	parts := strings.Split(settings, ",")
+	v.checkLevel("loggers", name, parts[0])
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "nopropagate":
+		case strings.HasPrefix(part, "stacktrace="):
+			v.checkLevel("loggers", name, part[len("stacktrace="):])
+		case strings.HasPrefix(part, "paniclevel="):
+			v.checkLevel("loggers", name, part[len("paniclevel="):])
+		case strings.HasPrefix(part, "onpanic="):
+			if _, err := ParsePanicAction(part[len("onpanic="):]); err != nil {
+				v.problem("loggers", name, err)
+			}
+		default:
+			output, err := parseLoggerOutput(part)
+			if err != nil {
+				v.problem("loggers", name, err)
+			}
+			if !v.known(output.Name) {
+				v.problem("loggers", name, ErrUnknownOutput(output.Name))
+			} else {
+				v.used[output.Name] = true
+			}
+		}
+	}
+}
+
+// Reports whether a name refers to an output section or a predefined outputter.
+func (v *validator) known(name string) bool {
+	return v.sections[name] != nil || v.predefined[name] != nil
+}
+
+func (v *validator) checkOutput(name string, options map[string]string) {
+	pluginType, ok := options["type"]
+	if !ok {
+		v.problem(name, "type", ErrTypeNotSpecified)
+		return
+	}
+	h := v.hierarchy
+	h.lock.Lock()
+	plugin := h.outputPlugins[pluginType]
+	composite := h.compositePlugins[pluginType]
+	known, declared := h.pluginOptions[pluginType]
+	references := h.referenceOptions[pluginType]
+	h.lock.Unlock()
+	if plugin == nil && composite == nil {
+		v.problem(name, "type", ErrUnknownPlugin(pluginType))
+		return
+	}
+
+	for _, key := range []string{"threshold", "stacktrace"} {
+		if value, ok := options[key]; ok {
+			v.checkLevel(name, key, value)
+		}
+	}
+
+	if declared {
+		allowed := make(map[string]bool)
+		for _, list := range [][]string{standardOptions, known} {
+			for _, option := range list {
+				allowed[option] = true
+			}
+		}
+		if allowed["formatter"] {
+			formatter := options["formatter"]
+			if formatter == "" {
+				formatter = "basic"
+			}
+			lock.Lock()
+			fmtOptions, ok := formatterOptions[formatter]
+			lock.Unlock()
+			if !ok {
+				v.problem(name, "formatter", errors.New("unknown formatter: "+formatter))
+			}
+			for _, option := range fmtOptions {
+				allowed[option] = true
+			}
+		}
+		for _, key := range sortedStrings(options) {
+			if !allowed[key] {
+				v.problem(name, key, fmt.Errorf("unknown option for %s output", pluginType))
+			}
+		}
+	}
+
+	// Find the sections that a composite section refers to, without creating any outputters
+	if composite != nil {
+		for _, key := range references {
+			for _, ref := range strings.Split(options[key], ",") {
+				if ref = strings.TrimSpace(ref); ref == "" {
+					continue
+				}
+				v.used[ref] = true
+				if !v.known(ref) {
+					v.problem(name, key, ErrUnknownOutput(ref))
+				} else if v.sections[ref] != nil {
+					v.references[name] = append(v.references[name], ref)
+				}
+			}
+		}
+	}
+}
+
+func (v *validator) checkCycles() {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			v.problem(name, "", ErrOutputCycle(name))
+			return false
+		case visited:
+			return true
+		}
+		state[name] = visiting
+		for _, ref := range v.references[name] {
+			if !visit(ref) {
+				break
+			}
+		}
+		state[name] = visited
+		return true
+	}
+	names := make([]string, 0, len(v.references))
+	for name := range v.references {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		visit(name)
+	}
+}
+
+func sortedStrings(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
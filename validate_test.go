@@ -0,0 +1,114 @@
+package logging
+
+import (
+	"errors"
+	"github.com/vaughan0/go-ini"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	file, err := ini.Load(strings.NewReader(`
+  [loggers]
+  root = INFO, console, missing
+  a = LOUD, console, stacktrace=NEVER
+
+  [console]
+  type = console
+  stream = stderr
+  format = $msg
+  colour = yes
+  threshold = SOMETIMES
+
+  [both]
+  type = tee
+  outputs = console, nowhere
+
+  [unused]
+  type = file
+  file = /dev/null
+  format = $msg
+
+  [strange]
+  type = strange
+  `))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ValidateConfig(IniConfig(file))
+	var problems ConfigErrors
+	if !errors.As(err, &problems) {
+		t.Fatalf("expected ConfigErrors, got %v", err)
+	}
+
+	found := make(map[string]int)
+	for _, problem := range problems {
+		found[problem.Section+" "+problem.Key]++
+		if problem.Warning != (problem.Key == "") {
+			t.Errorf("unexpected warning status: %v", problem)
+		}
+	}
+	expect := map[string]int{
+		"console threshold": 1,
+		"console colour":    1,
+		"both outputs":      1,
+		"strange type":      1,
+		"loggers a":         2,
+		"loggers root":      1,
+		"both ":             1,
+		"unused ":           1,
+		"strange ":          1,
+	}
+	if !reflect.DeepEqual(found, expect) {
+		t.Errorf("unexpected problems:\n%v", problems)
+	}
+}
+
+func TestSetupIsTransactional(t *testing.T) {
+	defer resetLoggers()
+	var logs msgSlice
+	RegisterOutputPlugin("mock", &logs)
+	mockSetup()
+
+	err := SetupReader(strings.NewReader(`
+  [loggers]
+  root = INFO, mock, typo
+  [mock]
+  type = mock
+  `))
+	if err == nil {
+		t.Fatal("invalid configuration was applied")
+	}
+	Get("transactional").Info("still logging")
+	if len(logs) != 1 {
+		t.Errorf("configuration was changed by an invalid configuration")
+	}
+}
+
+func TestValidateCompositeWithoutCreating(t *testing.T) {
+	h := NewHierarchy()
+	created := 0
+	h.RegisterCompositePlugin("counted", CompositePluginFunc(func(options map[string]string, resolve func(string) (Outputter, error)) (Outputter, error) {
+		created++
+		return resolve(options["target"])
+	}))
+	h.RegisterReferenceOptions("counted", "target")
+	config := IniConfig{
+		"loggers": {"root": "INFO, counted"},
+		"counted": {"type": "counted", "target": "nowhere"},
+	}
+
+	err := h.ValidateConfig(config)
+	var problems ConfigErrors
+	if !errors.As(err, &problems) || len(problems) != 1 || problems[0].Section != "counted" || problems[0].Key != "target" {
+		t.Fatalf("unexpected problems: %v", err)
+	}
+	var unknown ErrUnknownOutput
+	if !errors.As(err, &unknown) || string(unknown) != "nowhere" {
+		t.Errorf("expected ErrUnknownOutput, got %v", err)
+	}
+	if created != 0 {
+		t.Errorf("composite plugin was called %d times during validation", created)
+	}
+}
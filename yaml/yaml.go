@@ -0,0 +1,27 @@
+// Package yaml adds support for YAML configuration files to go-logging. Importing it registers the ".yaml" and ".yml"
+// extensions with logging.SetupFile.
+package yaml
+
+import (
+	"github.com/vaughan0/go-logging"
+	"gopkg.in/yaml.v3"
+	"io"
+)
+
+// Loads a logging.StructuredConfig from an io.Reader, which should return a YAML document.
+func LoadYAMLConfig(input io.Reader) (*logging.StructuredConfig, error) {
+	var document map[string]interface{}
+	if err := yaml.NewDecoder(input).Decode(&document); err != nil {
+		return nil, err
+	}
+	return logging.NewStructuredConfig(document)
+}
+
+func loader(input io.Reader) (logging.Config, error) {
+	return LoadYAMLConfig(input)
+}
+
+func init() {
+	logging.RegisterConfigFormat(".yaml", loader)
+	logging.RegisterConfigFormat(".yml", loader)
+}
@@ -0,0 +1,60 @@
+package yaml
+
+import (
+	"github.com/vaughan0/go-logging"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The YAML version of the document in the logging package's TestJSONConfig.
+const document = `
+loggers:
+  root: INFO, console
+  a.b:
+    level: DEBUG
+    outputs:
+      - console
+      - type: tee
+        outputs: [console, file]
+    nopropagate: true
+console:
+  type: console
+  stream: stderr
+  format: $msg
+file:
+  type: file
+  file: /dev/null
+  format: $msg
+  threshold: WARN
+`
+
+const jsonDocument = `{
+	"loggers": {
+		"root": "INFO, console",
+		"a.b": {
+			"level": "DEBUG",
+			"outputs": ["console", {"type": "tee", "outputs": ["console", "file"]}],
+			"nopropagate": true
+		}
+	},
+	"console": {"type": "console", "stream": "stderr", "format": "$msg"},
+	"file": {"type": "file", "file": "/dev/null", "format": "$msg", "threshold": "WARN"}
+}`
+
+func TestLoadYAMLConfig(t *testing.T) {
+	config, err := LoadYAMLConfig(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := logging.LoadJSONConfig(strings.NewReader(jsonDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config.LoggerSettings(), expect.LoggerSettings()) {
+		t.Errorf("unexpected logger settings: %v", config.LoggerSettings())
+	}
+	if !reflect.DeepEqual(config.Plugins(), expect.Plugins()) {
+		t.Errorf("unexpected outputs: %v", config.Plugins())
+	}
+}